@@ -5,6 +5,7 @@ import (
 	"github.com/thk-im/thk-im-livecall-server/pkg/app"
 	"github.com/thk-im/thk-im-livecall-server/pkg/conf"
 	"github.com/thk-im/thk-im-livecall-server/pkg/handler"
+	"github.com/thk-im/thk-im-livecall-server/pkg/logic"
 )
 
 func main() {
@@ -17,6 +18,7 @@ func main() {
 	appCtx := &app.Context{}
 	appCtx.Init(config)
 	handler.RegisterRtcHandler(appCtx)
+	logic.NewRoomLogic(appCtx).StartScheduler()
 
 	appCtx.StartServe()
 }
@@ -2,23 +2,38 @@ package app
 
 import (
 	"github.com/sirupsen/logrus"
+	"github.com/thk-im/thk-im-base-server/locker"
 	"github.com/thk-im/thk-im-base-server/server"
 	"github.com/thk-im/thk-im-livecall-server/pkg/conf"
 	"github.com/thk-im/thk-im-livecall-server/pkg/loader"
+	"github.com/thk-im/thk-im-livecall-server/pkg/model"
 	"github.com/thk-im/thk-im-livecall-server/pkg/sdk"
+	"github.com/thk-im/thk-im-livecall-server/pkg/service/room/cache"
 	msgSdk "github.com/thk-im/thk-im-msgapi-server/pkg/sdk"
 	rtcSdk "github.com/thk-im/thk-im-rtc-server/pkg/sdk"
 )
 
 type Context struct {
-	startTime int64
-	logger    *logrus.Entry
+	startTime    int64
+	logger       *logrus.Entry
+	config       *conf.LiveCallConfig
+	roomCache    cache.RoomCache
+	localLockers *cache.LocalLockers
 	*server.Context
 }
 
+// Init 初始化基础服务和房间存储; Cache.Cluster为local时只有房间数据和锁保存在进程内,
+// 日志、数据库(通话记录)和http服务仍由基础服务按配置初始化, 基础配置中的Redis/MySQL仍需可用
 func (c *Context) Init(config *conf.LiveCallConfig) {
+	c.config = config
 	c.Context = &server.Context{}
 	c.Context.Init(config.Config)
+	if config.Cache != nil && config.Cache.Cluster == cache.ClusterLocal {
+		c.roomCache = cache.MakeLocalCache(c.Logger())
+		c.localLockers = cache.MakeLocalLockers()
+	} else {
+		c.roomCache = cache.MakeRedisCache(c.RedisCache(), c.Logger())
+	}
 	c.Context.SdkMap = loader.LoadSdks(config, c.Logger())
 	c.Context.ModelMap = loader.LoadModels(c.Config().Models, c.Database(), c.Logger(), c.SnowflakeNode())
 	err := loader.LoadTables(c.Config().Models, c.Database())
@@ -27,11 +42,53 @@ func (c *Context) Init(config *conf.LiveCallConfig) {
 	}
 }
 
+// NewLocalContext 不初始化基础服务的单节点上下文, 房间数据和锁保存在进程内, 不连接数据库和redis, 用于单元测试
+func NewLocalContext(config *conf.LiveCallConfig, logger *logrus.Entry) *Context {
+	return &Context{
+		logger:       logger,
+		config:       config,
+		roomCache:    cache.MakeLocalCache(logger),
+		localLockers: cache.MakeLocalLockers(),
+		Context: &server.Context{
+			SdkMap:   make(map[string]interface{}),
+			ModelMap: make(map[string]interface{}),
+		},
+	}
+}
+
+// Logger 未初始化基础服务时使用创建上下文时传入的日志
+func (c *Context) Logger() *logrus.Entry {
+	if c.logger != nil {
+		return c.logger
+	}
+	return c.Context.Logger()
+}
+
+func (c *Context) LiveCallConfig() *conf.LiveCallConfig {
+	return c.config
+}
+
+// RoomCache 房间数据存储, 由Cache.Cluster决定使用进程内存储还是redis
+func (c *Context) RoomCache() cache.RoomCache {
+	return c.roomCache
+}
+
+// NewRoomLocker 单节点模式使用进程内锁, 否则使用redis分布式锁, waitTime和timeout单位ms
+func (c *Context) NewRoomLocker(key string, waitTime, timeout int) locker.Locker {
+	if c.localLockers != nil {
+		return c.localLockers.NewLocker(key, waitTime, timeout)
+	}
+	return c.Context.NewLocker(key, waitTime, timeout)
+}
+
 func (c *Context) LoginApi() msgSdk.LoginApi {
 	return c.Context.SdkMap["login_api"].(msgSdk.LoginApi)
 }
 
 func (c *Context) MsgApi() msgSdk.MsgApi {
+	if c.Context.SdkMap["msg_api"] == nil {
+		return nil
+	}
 	return c.Context.SdkMap["msg_api"].(msgSdk.MsgApi)
 }
 
@@ -49,6 +106,20 @@ func (c *Context) CloudflareConnectApi() sdk.SfuApi {
 	return c.Context.SdkMap["cloudflare_connect_api"].(sdk.SfuApi)
 }
 
+func (c *Context) CallRecordModel() model.CallRecordModel {
+	if c.Context.ModelMap["live_call_record"] == nil {
+		return nil
+	}
+	return c.Context.ModelMap["live_call_record"].(model.CallRecordModel)
+}
+
+func (c *Context) CallLegModel() model.CallLegModel {
+	if c.Context.ModelMap["live_call_leg"] == nil {
+		return nil
+	}
+	return c.Context.ModelMap["live_call_leg"].(model.CallLegModel)
+}
+
 func (c *Context) StartServe() {
 	c.Context.StartServe()
 }
@@ -12,13 +12,59 @@ type Rtc struct {
 }
 
 type Cache struct {
-	Cluster string                `yaml:"Cluster"`
-	Redis   *baseConf.RedisSource `yaml:"RedisSource"`
+	Cluster string                `yaml:"Cluster"`     // redis/local
+	Redis   *baseConf.RedisSource `yaml:"RedisSource"` // 暂未使用, redis模式使用基础配置中的RedisSource
+}
+
+type RoomCheck struct {
+	Interval        int64 `yaml:"Interval"`        // 房间检查间隔 单位s
+	GracePeriod     int64 `yaml:"GracePeriod"`     // 房间无媒体流后的销毁宽限期 单位s
+	RingGracePeriod int64 `yaml:"RingGracePeriod"` // 呼叫中的房间没有待处理的响铃超时后的销毁宽限期 单位s
+}
+
+type Heartbeat struct {
+	Interval int64 `yaml:"Interval"` // 心跳检查间隔 单位s
+	Timeout  int64 `yaml:"Timeout"`  // 超过该时间未上报心跳视为停止推流/离开房间 单位s
+}
+
+// CallMsg 通话结束后发送的会话消息, 未配置时与旧版本一致向整个会话发送一条类型为14的通话小结
+type CallMsg struct {
+	SummaryType int  `yaml:"SummaryType"` // 通话小结
+	MissedType  int  `yaml:"MissedType"`  // 未接来电, 发送给未接听的被邀请人
+	PerMember   bool `yaml:"PerMember"`   // 按成员发送, 未进入房间的会话成员不再收到通话小结
+}
+
+// Adapter 媒体适配器的WebSocket地址, 用于转写、AI助手等服务接入通话音频
+type Adapter struct {
+	Name     string `yaml:"Name"`
+	Endpoint string `yaml:"Endpoint"`
+}
+
+// MediaParams 媒体参数, 码率单位bps, 视频参数为0表示该模式不推视频
+type MediaParams struct {
+	VideoMaxBitrate int `yaml:"VideoMaxBitrate"`
+	VideoWidth      int `yaml:"VideoWidth"`
+	VideoHeight     int `yaml:"VideoHeight"`
+	VideoFps        int `yaml:"VideoFps"`
+	AudioMaxBitrate int `yaml:"AudioMaxBitrate"`
+}
+
+// MediaProfile 房间模式的默认媒体参数, 客户端传入的参数不超过Max
+type MediaProfile struct {
+	Mode    int          `yaml:"Mode"`
+	Default *MediaParams `yaml:"Default"`
+	Max     *MediaParams `yaml:"Max"`
 }
 
 type LiveCallConfig struct {
-	Rtc              *Rtc   `yaml:"Rtc"`
-	Cache            *Cache `yaml:"Cache"`
-	SignalType       int    `yaml:"SignalType"`
+	Rtc              *Rtc           `yaml:"Rtc"`
+	Cache            *Cache         `yaml:"Cache"`
+	RoomCheck        *RoomCheck     `yaml:"RoomCheck"`
+	Heartbeat        *Heartbeat     `yaml:"Heartbeat"`
+	CallMsg          *CallMsg       `yaml:"CallMsg"`
+	SignalType       int            `yaml:"SignalType"`
+	Engine           string         `yaml:"Engine"` // 默认RTC引擎 WebRTC/CloudflareSFU
+	Adapters         []Adapter      `yaml:"Adapters"`
+	MediaProfiles    []MediaProfile `yaml:"MediaProfiles"`
 	*baseConf.Config `yaml:",inline"`
 }
@@ -0,0 +1,39 @@
+package dto
+
+const (
+	AdapterForward = "forward" // 成员track转发到WebSocket
+	AdapterInject  = "inject"  // WebSocket音频推入房间
+)
+
+type (
+	// RoomAdapter 房间内创建的WebSocket适配器
+	RoomAdapter struct {
+		AdapterId  string `json:"adapter_id"`
+		Direction  string `json:"direction"`   // forward/inject
+		Name       string `json:"name"`        // 适配器名, 对应配置Adapters
+		UId        int64  `json:"u_id"`        // 被转发的成员/推入音频使用的用户id
+		SessionId  string `json:"session_id"`  // track所在会话id
+		TrackName  string `json:"track_name"`  // track名
+		CreateTime int64  `json:"create_time"` // 创建时间
+	}
+
+	ForwardTrackReq struct {
+		RoomId      string `json:"room_id"`
+		MemberUId   int64  `json:"member_u_id"`
+		TrackName   string `json:"track_name"`   // 为空时转发mic
+		Name        string `json:"name"`         // 适配器名, 对应配置Adapters
+		OutputCodec string `json:"output_codec"` // pcm/jpeg, 为空时pcm
+	}
+
+	InjectAudioReq struct {
+		RoomId    string `json:"room_id"`
+		UId       int64  `json:"u_id"`       // 推入的音频在房间内使用的用户id
+		TrackName string `json:"track_name"` // 为空时为mic
+		Name      string `json:"name"`       // 适配器名, 对应配置Adapters
+	}
+
+	CloseAdapterReq struct {
+		RoomId     string   `json:"room_id"`
+		AdapterIds []string `json:"adapter_ids"`
+	}
+)
@@ -1,53 +1,91 @@
 package dto
 
-import (
-	"fmt"
-	"time"
-)
+import "time"
 
 type CallMsg struct {
-	RoomId      string  `json:"room_id"`
-	RoomOwnerId int64   `json:"room_owner_id"`
-	RoomMode    int     `json:"room_mode"`
-	CreateTime  int64   `json:"create_time"`
-	Accepted    int     `json:"accepted"` // 0未接听 1被挂断 2已接通 3通话中被挂断
-	AcceptTime  int64   `json:"accept_time"`
-	Duration    int64   `json:"duration"`
-	JoinedUIds  []int64 `json:"joined_u_ids"`
+	RoomId      string        `json:"room_id"`
+	RoomOwnerId int64         `json:"room_owner_id"`
+	RoomMode    int           `json:"room_mode"`
+	CreateTime  int64         `json:"create_time"`
+	Accepted    int           `json:"accepted"` // 0未接听 1被挂断 2已接通 3通话中被挂断
+	AcceptTime  int64         `json:"accept_time"`
+	Duration    int64         `json:"duration"`
+	JoinedUIds  []int64       `json:"joined_u_ids"`
+	EndTime     int64         `json:"end_time"`   // 通话结束时间
+	EndUId      int64         `json:"end_u_id"`   // 结束通话的用户, 0为系统结束
+	EndReason   string        `json:"end_reason"` // 通话结束原因, 见EndReason*
+	MediaMode   string        `json:"media_mode"` // 实际使用的媒体类型 audio/video
+	PeakCount   int           `json:"peak_count"` // 同时在通话中的最大人数
+	Legs        []*CallMsgLeg `json:"legs"`       // 每个成员的通话情况
 }
 
+// CallMsgLeg 成员的通话情况
+type CallMsgLeg struct {
+	UId        int64  `json:"u_id"`
+	State      int    `json:"state"` // 结束时的通话状态, 见CallState*
+	InviterId  int64  `json:"inviter_id"`
+	JoinTime   int64  `json:"join_time"`  // 最近一次加入时间
+	LeaveTime  int64  `json:"leave_time"` // 最近一次离开时间
+	AnswerTime int64  `json:"answer_time"`
+	Duration   int64  `json:"duration"` // 累计通话时长, 单位ms
+	EndReason  string `json:"end_reason"`
+}
+
+// BuildCallMsg 通话结束时间取房间进入结束状态的时间, 房间未结束时取当前时间
 func BuildCallMsg(room *Room) CallMsg {
 	accepted := 0
+	declined, busy := false, false
 	acceptTime := int64(0)
 	duration := int64(0)
-	leaveTime := time.Now().UnixMilli()
+	endTime := time.Now().UnixMilli()
+	if room.IsEnded() && room.StatusTime > 0 {
+		endTime = room.StatusTime
+	}
+	mediaMode := callMediaMode(room)
 	joinedUIds := make([]int64, 0)
+	legs := make([]*CallMsgLeg, 0, len(room.Participants))
 	for _, p := range room.Participants {
-		fmt.Printf("BuildCallMsg %d %d", p.UId, p.JoinTime)
 		if p.UId != room.OwnerId {
-			if p.JoinTime > 0 {
-				accepted = 2
-				// 不算房主最早加入的人
-				if p.JoinTime < acceptTime || acceptTime == 0 {
-					acceptTime = p.JoinTime
-				}
+			// 不算房主最早接听的人
+			if p.AnswerTime > 0 && (p.AnswerTime < acceptTime || acceptTime == 0) {
+				acceptTime = p.AnswerTime
 			}
-			if p.Refuse == 1 {
-				accepted = 1
-			} else if p.Refuse == 2 {
-				accepted = 3
+			if p.State == CallStateDeclined {
+				if p.EndReason == EndReasonBusy {
+					busy = true
+				} else {
+					declined = true
+				}
 			}
 		}
 		if p.JoinTime > 0 {
 			joinedUIds = append(joinedUIds, p.UId)
 		}
+		legs = append(legs, &CallMsgLeg{
+			UId:        p.UId,
+			State:      p.State,
+			InviterId:  p.InviterId,
+			JoinTime:   p.JoinTime,
+			LeaveTime:  p.LeaveTime,
+			AnswerTime: p.AnswerTime,
+			Duration:   p.CallDuration(endTime),
+			EndReason:  p.EndReason,
+		})
 	}
-	if accepted > 0 {
-		duration = leaveTime - acceptTime
+	if acceptTime > 0 {
+		accepted = 2
+		duration = endTime - acceptTime
+	}
+	// 有人拒绝时以拒绝为准, 即使其他人已接通; 忙线优先于普通拒绝
+	if busy {
+		accepted = 3
+	} else if declined {
+		accepted = 1
 	}
 	// 房主已经离开了，其他人在进来的
 	if duration < 0 {
 		accepted = 0
+		duration = 0
 	}
 	return CallMsg{
 		RoomId:      room.Id,
@@ -58,5 +96,49 @@ func BuildCallMsg(room *Room) CallMsg {
 		AcceptTime:  acceptTime,
 		Duration:    duration,
 		JoinedUIds:  joinedUIds,
+		EndTime:     endTime,
+		EndUId:      room.EndUId,
+		EndReason:   room.EndReason,
+		MediaMode:   mediaMode,
+		PeakCount:   room.PeakCount,
+		Legs:        legs,
+	}
+}
+
+// callMediaMode 按成员实际推流过的track类型确定媒体类型, 有人推过视频即为视频通话; 没有人推流时按房间媒体参数
+func callMediaMode(room *Room) string {
+	published := false
+	for _, p := range room.Participants {
+		if p.HasPublished(TrackKindVideo) {
+			return TrackKindVideo
+		}
+		if p.HasPublished(TrackKindAudio) {
+			published = true
+		}
 	}
+	if !published && room.MediaParams.VideoEnable() {
+		return TrackKindVideo
+	}
+	return TrackKindAudio
+}
+
+// AttendeeView 参与过通话的成员收到的小结, 接听时间和时长按该成员自己的通话计算, 房主沿用整体的通话情况
+func (c CallMsg) AttendeeView(p *Participant) CallMsg {
+	if p.UId == c.RoomOwnerId || p.AnswerTime == 0 {
+		return c
+	}
+	view := c
+	view.Accepted = 2
+	view.AcceptTime = p.AnswerTime
+	view.Duration = p.CallDuration(c.EndTime)
+	return view
+}
+
+// MissedView 未接听的被邀请人收到的未接来电
+func (c CallMsg) MissedView() CallMsg {
+	view := c
+	view.Accepted = 0
+	view.AcceptTime = 0
+	view.Duration = 0
+	return view
 }
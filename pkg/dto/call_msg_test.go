@@ -0,0 +1,81 @@
+package dto
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCallMsgMediaMode(t *testing.T) {
+	videoParams := &MediaParams{AudioMaxBitrate: 64000, VideoMaxBitrate: 1500000}
+	cases := []struct {
+		name         string
+		params       *MediaParams
+		participants []*Participant
+		want         string
+	}{
+		{"video room audio only", videoParams, []*Participant{
+			{UId: 1, Tracks: []*ParticipantTrack{{Kind: TrackKindAudio, TrackName: TrackMic}}},
+			{UId: 2, PublishedKinds: []string{TrackKindAudio}},
+		}, TrackKindAudio},
+		{"video published then stopped", videoParams, []*Participant{
+			{UId: 1, PublishedKinds: []string{TrackKindAudio, TrackKindVideo}},
+		}, TrackKindVideo},
+		{"screen share is not video", videoParams, []*Participant{
+			{UId: 1, Tracks: []*ParticipantTrack{{Kind: TrackKindAudio, TrackName: TrackMic}, {Kind: TrackKindVideo, TrackName: TrackScreen}}},
+		}, TrackKindAudio},
+		{"nobody published video room", videoParams, []*Participant{{UId: 1}, {UId: 2}}, TrackKindVideo},
+		{"nobody published audio room", &MediaParams{AudioMaxBitrate: 64000}, []*Participant{{UId: 1}}, TrackKindAudio},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			msg := BuildCallMsg(&Room{Id: "room", OwnerId: 1, MediaParams: c.params, Participants: c.participants})
+			if msg.MediaMode != c.want {
+				t.Fatalf("media mode %s, want %s", msg.MediaMode, c.want)
+			}
+		})
+	}
+}
+
+func TestAddPublishedKinds(t *testing.T) {
+	p := &Participant{}
+	p.AddPublishedKinds([]*ParticipantTrack{
+		{Kind: TrackKindAudio, TrackName: TrackMic},
+		{Kind: TrackKindVideo, TrackName: TrackScreen},
+		{Kind: TrackKindAudio, TrackName: TrackMic},
+	})
+	if len(p.PublishedKinds) != 1 || p.PublishedKinds[0] != TrackKindAudio {
+		t.Fatalf("unexpected kinds %v", p.PublishedKinds)
+	}
+}
+
+func TestCallMsgAccepted(t *testing.T) {
+	now := time.Now().UnixMilli()
+	answered := &Participant{UId: 2, State: CallStateAccepted, AnswerTime: now - 1000}
+	declined := &Participant{UId: 3, State: CallStateDeclined}
+	busy := &Participant{UId: 4, State: CallStateDeclined, EndReason: EndReasonBusy}
+	cases := []struct {
+		name         string
+		participants []*Participant
+		want         int
+	}{
+		{"nobody answered", []*Participant{{UId: 1}, {UId: 2, State: CallStateTimeout}}, 0},
+		{"answered", []*Participant{{UId: 1}, answered}, 2},
+		{"declined", []*Participant{{UId: 1}, declined}, 1},
+		{"declined over answered", []*Participant{{UId: 1}, answered, declined}, 1},
+		{"busy over declined", []*Participant{{UId: 1}, declined, busy}, 3},
+		{"busy over answered", []*Participant{{UId: 1}, answered, busy}, 3},
+		{"owner declined not counted", []*Participant{{UId: 1, State: CallStateDeclined}, answered}, 2},
+		{"answered after end", []*Participant{{UId: 1}, {UId: 2, AnswerTime: now + 60000}}, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			msg := BuildCallMsg(&Room{Id: "room", OwnerId: 1, Status: RoomStatusEnded, StatusTime: now, Participants: c.participants})
+			if msg.Accepted != c.want {
+				t.Fatalf("accepted %d, want %d", msg.Accepted, c.want)
+			}
+			if msg.Duration < 0 {
+				t.Fatalf("negative duration %d", msg.Duration)
+			}
+		})
+	}
+}
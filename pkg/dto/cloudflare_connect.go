@@ -26,6 +26,7 @@ type TrackObject struct {
 	BidirectionalMediaStream bool             `json:"bidirectionalMediaStream,omitempty"`
 	Kind                     string           `json:"kind,omitempty"` // audio / video
 	Simulcast                *SimulcastConfig `json:"simulcast,omitempty"`
+	Rids                     []string         `json:"-"` // 推流sdp中声明的simulcast层, 仅本地记录
 }
 
 type SimulcastConfig struct {
@@ -197,10 +198,12 @@ type AdapterResponseTrack struct {
 	ErrorDescription string `json:"errorDescription,omitempty"`
 }
 
+type CloseAdapterObject struct {
+	AdapterID string `json:"adapterId,omitempty"`
+}
+
 type CloseAdapterRequest struct {
-	Tracks []struct {
-		AdapterID string `json:"adapterId,omitempty"`
-	} `json:"tracks,omitempty"`
+	Tracks []CloseAdapterObject `json:"tracks,omitempty"`
 }
 
 type CloseAdapterResponse struct {
@@ -273,18 +276,21 @@ type CloseDataChannelsResponse struct {
 	DataChannels     []DataChannelResponseItem `json:"dataChannels,omitempty"`
 }
 
+// ParseTracksFromSDP 按m-line顺序命名track, 第一路音视频为mic/camera, 第二路为screen_audio/screen, 同时解析simulcast rid
 func ParseTracksFromSDP(sdp string, sessionId string) []TrackObject {
 	var tracks []TrackObject
 
 	scanner := bufio.NewScanner(strings.NewReader(sdp))
 
 	var currentKind string
+	current := -1
 
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 
 		// 找 m=
 		if strings.HasPrefix(line, "m=") {
+			current = -1
 			if strings.HasPrefix(line, "m=audio") {
 				currentKind = "audio"
 			} else if strings.HasPrefix(line, "m=video") {
@@ -298,12 +304,7 @@ func ParseTracksFromSDP(sdp string, sessionId string) []TrackObject {
 		if strings.HasPrefix(line, "a=mid:") && currentKind != "" {
 			mid := strings.TrimPrefix(line, "a=mid:")
 
-			trackName := ""
-			if currentKind == "audio" {
-				trackName = "mic"
-			} else if currentKind == "video" {
-				trackName = "camera"
-			}
+			trackName := defaultTrackName(currentKind, mid, tracks)
 
 			tracks = append(tracks, TrackObject{
 				Location:  "local",
@@ -312,8 +313,55 @@ func ParseTracksFromSDP(sdp string, sessionId string) []TrackObject {
 				Kind:      currentKind,
 				TrackName: trackName,
 			})
+			current = len(tracks) - 1
+		}
+
+		// 找 simulcast rid, 如 a=rid:h send
+		if strings.HasPrefix(line, "a=rid:") && current >= 0 {
+			fields := strings.Fields(strings.TrimPrefix(line, "a=rid:"))
+			if len(fields) >= 2 && fields[1] == "send" {
+				tracks[current].Rids = append(tracks[current].Rids, fields[0])
+			}
 		}
 	}
 
 	return tracks
 }
+
+func defaultTrackName(kind, mid string, tracks []TrackObject) string {
+	names := []string{TrackMic, TrackScreenAudio}
+	if kind == TrackKindVideo {
+		names = []string{TrackCamera, TrackScreen}
+	}
+	for _, name := range names {
+		used := false
+		for _, t := range tracks {
+			if t.TrackName == name {
+				used = true
+				break
+			}
+		}
+		if !used {
+			return name
+		}
+	}
+	return kind + "_" + mid
+}
+
+// NameTracks 按mid使用客户端指定的track名和simulcast层
+func NameTracks(tracks []TrackObject, named []*StreamTrack) []TrackObject {
+	for i := range tracks {
+		for _, n := range named {
+			if n.Mid != tracks[i].Mid {
+				continue
+			}
+			if n.TrackName != "" {
+				tracks[i].TrackName = n.TrackName
+			}
+			if len(n.Rids) > 0 {
+				tracks[i].Rids = n.Rids
+			}
+		}
+	}
+	return tracks
+}
@@ -0,0 +1,86 @@
+package dto
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testOfferSdp = "v=0\r\n" +
+	"o=- 1 2 IN IP4 127.0.0.1\r\n" +
+	"s=-\r\n" +
+	"t=0 0\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+	"a=mid:0\r\n" +
+	"a=sendonly\r\n" +
+	"m=video 9 UDP/TLS/RTP/SAVPF 96\r\n" +
+	"a=mid:1\r\n" +
+	"a=rid:h send\r\n" +
+	"a=rid:l send\r\n" +
+	"a=rid:x recv\r\n" +
+	"a=sendonly\r\n" +
+	"m=video 9 UDP/TLS/RTP/SAVPF 96\r\n" +
+	"a=mid:2\r\n" +
+	"a=sendonly\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+	"a=mid:3\r\n" +
+	"a=sendonly\r\n" +
+	"m=video 9 UDP/TLS/RTP/SAVPF 96\r\n" +
+	"a=mid:4\r\n" +
+	"a=sendonly\r\n" +
+	"m=application 9 UDP/DTLS/SCTP webrtc-datachannel\r\n" +
+	"a=mid:5\r\n"
+
+func TestParseTracksFromSDP(t *testing.T) {
+	tracks := ParseTracksFromSDP(testOfferSdp, "session")
+	want := []struct {
+		mid, kind, name string
+		rids            []string
+	}{
+		{"0", TrackKindAudio, TrackMic, nil},
+		{"1", TrackKindVideo, TrackCamera, []string{"h", "l"}},
+		{"2", TrackKindVideo, TrackScreen, nil},
+		{"3", TrackKindAudio, TrackScreenAudio, nil},
+		{"4", TrackKindVideo, "video_4", nil},
+	}
+	if len(tracks) != len(want) {
+		t.Fatalf("unexpected tracks %+v", tracks)
+	}
+	for i, w := range want {
+		tr := tracks[i]
+		if tr.Mid != w.mid || tr.Kind != w.kind || tr.TrackName != w.name || tr.SessionID != "session" || tr.Location != "local" {
+			t.Errorf("track %d = %+v, want %+v", i, tr, w)
+		}
+		if !reflect.DeepEqual(tr.Rids, w.rids) {
+			t.Errorf("track %d rids %v, want %v", i, tr.Rids, w.rids)
+		}
+	}
+	if tracks := ParseTracksFromSDP("", "session"); len(tracks) != 0 {
+		t.Fatalf("unexpected tracks %+v", tracks)
+	}
+}
+
+func TestNameTracks(t *testing.T) {
+	cases := []struct {
+		name      string
+		named     []*StreamTrack
+		wantNames []string
+		wantRids  []string
+	}{
+		{"no names", nil, []string{TrackMic, TrackCamera}, []string{"h", "l"}},
+		{"client names", []*StreamTrack{{Mid: "1", TrackName: TrackScreen}}, []string{TrackMic, TrackScreen}, []string{"h", "l"}},
+		{"client rids", []*StreamTrack{{Mid: "1", Rids: []string{"f"}}}, []string{TrackMic, TrackCamera}, []string{"f"}},
+		{"unknown mid", []*StreamTrack{{Mid: "9", TrackName: TrackScreen}}, []string{TrackMic, TrackCamera}, []string{"h", "l"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tracks := NameTracks(ParseTracksFromSDP(testOfferSdp, "")[:2], c.named)
+			names := []string{tracks[0].TrackName, tracks[1].TrackName}
+			if !reflect.DeepEqual(names, c.wantNames) {
+				t.Fatalf("names %v, want %v", names, c.wantNames)
+			}
+			if !reflect.DeepEqual(tracks[1].Rids, c.wantRids) {
+				t.Fatalf("rids %v, want %v", tracks[1].Rids, c.wantRids)
+			}
+		})
+	}
+}
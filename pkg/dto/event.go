@@ -18,5 +18,6 @@ type (
 		RoomId    string `json:"room_id"`
 		UserId    int64  `json:"user_id"`
 		Timestamp int64  `json:"timestamp"`
+		Reason    string `json:"reason"` // 离开原因, 为空时按主动挂断处理
 	}
 )
@@ -0,0 +1,61 @@
+package dto
+
+const (
+	DefaultHistoryCount = 20
+	MaxHistoryCount     = 100
+)
+
+type (
+	// CallLegVo 参与人通话记录
+	CallLegVo struct {
+		RoomId     string `json:"room_id"`
+		UId        int64  `json:"u_id"`
+		SessionId  int64  `json:"session_id"`
+		OwnerId    int64  `json:"owner_id"`
+		Mode       int    `json:"mode"`
+		Engine     string `json:"engine"`
+		Role       int    `json:"role"`
+		JoinTime   int64  `json:"join_time"`
+		LeaveTime  int64  `json:"leave_time"`
+		RefuseTime int64  `json:"refuse_time"`
+		KickTime   int64  `json:"kick_time"`
+		Duration   int64  `json:"duration"`
+		CreateTime int64  `json:"create_time"`
+		EndTime    int64  `json:"end_time"`
+	}
+
+	// CallRecordVo 会话通话记录
+	CallRecordVo struct {
+		RoomId      string `json:"room_id"`
+		SessionId   int64  `json:"session_id"`
+		OwnerId     int64  `json:"owner_id"`
+		Mode        int    `json:"mode"`
+		Engine      string `json:"engine"`
+		Accepted    int    `json:"accepted"`
+		AcceptTime  int64  `json:"accept_time"`
+		Duration    int64  `json:"duration"`
+		MemberCount int    `json:"member_count"`
+		CreateTime  int64  `json:"create_time"`
+		EndTime     int64  `json:"end_time"`
+	}
+
+	QueryUserCallHistoryReq struct {
+		UId    int64 `json:"u_id" form:"u_id"`
+		Offset int   `json:"offset" form:"offset"`
+		Count  int   `json:"count" form:"count"`
+	}
+
+	QueryUserCallHistoryResp struct {
+		Data []*CallLegVo `json:"data"`
+	}
+
+	QuerySessionCallHistoryReq struct {
+		SessionId int64 `json:"session_id" form:"session_id"`
+		Offset    int   `json:"offset" form:"offset"`
+		Count     int   `json:"count" form:"count"`
+	}
+
+	QuerySessionCallHistoryResp struct {
+		Data []*CallRecordVo `json:"data"`
+	}
+)
@@ -5,15 +5,180 @@ import "encoding/json"
 const (
 	Audience  = 1
 	Broadcast = 2
+
+	TrackKindAudio = "audio"
+	TrackKindVideo = "video"
+
+	TrackMic         = "mic"          // 麦克风
+	TrackCamera      = "camera"       // 摄像头
+	TrackScreen      = "screen"       // 屏幕共享画面
+	TrackScreenAudio = "screen_audio" // 屏幕共享声音
+
+	RidHigh = "high" // simulcast高清层
+	RidMid  = "mid"  // simulcast标清层
+	RidLow  = "low"  // simulcast低清层, 宫格小窗使用
 )
 
 type Participant struct {
-	UId       int64  `json:"u_id"`       // 用户id
-	Role      int    `json:"role"`       // 1推流 2观众
-	Refuse    int    `json:"refuse"`     // 是否拒绝 0未拒绝 1 拒绝 2 通话中拒绝
-	JoinTime  int64  `json:"join_time"`  // 加入时间
-	LeaveTime int64  `json:"leave_time"` // 离开时间
-	StreamKey string `json:"stream_key"` // 订阅流的key
+	UId             int64                     `json:"u_id"`              // 用户id
+	Role            int                       `json:"role"`              // 1观众 2推流
+	RoomRole        int                       `json:"room_role"`         // 0成员 1管理员 2房主
+	Refuse          int                       `json:"refuse"`            // 是否拒绝 0未拒绝 1 拒绝 2 通话中拒绝
+	JoinTime        int64                     `json:"join_time"`         // 加入时间
+	LeaveTime       int64                     `json:"leave_time"`        // 离开时间
+	TimeoutTime     int64                     `json:"timeout_time"`      // 超时未接听时间
+	RefuseTime      int64                     `json:"refuse_time"`       // 拒绝时间
+	KickTime        int64                     `json:"kick_time"`         // 被踢出时间
+	HandTime        int64                     `json:"hand_time"`         // 举手申请发言时间, 0未举手, 按该时间排序即为申请队列
+	EventTime       int64                     `json:"event_time"`        // 最近处理的加入/离开事件时间, 更早的事件视为过期
+	StreamEventTime int64                     `json:"stream_event_time"` // 最近处理的推流开始/结束事件时间, 更早的事件视为过期
+	StreamKey       string                    `json:"stream_key"`        // 订阅流的key
+	AudioMuted      bool                      `json:"audio_muted"`       // 麦克风是否被静音
+	VideoMuted      bool                      `json:"video_muted"`       // 摄像头是否被关闭
+	Tracks          []*ParticipantTrack       `json:"tracks"`            // 推流的track
+	PublishedKinds  []string                  `json:"published_kinds"`   // 通话中推流过的音视频类型, 不含屏幕共享, 停止推流后保留
+	Subscriptions   []*ParticipantTrack       `json:"subscriptions"`     // 拉流的track
+	DataChannels    []*ParticipantDataChannel `json:"data_channels"`     // 发布的数据通道
+	State           int                       `json:"state"`             // 通话状态, 见CallState*
+	InviterId       int64                     `json:"inviter_id"`        // 最近一次邀请人
+	InviteTime      int64                     `json:"invite_time"`       // 最近一次邀请时间
+	RingTime        int64                     `json:"ring_time"`         // 呼叫信令送达时间
+	AnswerTime      int64                     `json:"answer_time"`       // 首次接听时间
+	EndReason       string                    `json:"end_reason"`        // 通话结束原因, 见EndReason*
+	StateHistory    []*ParticipantState       `json:"state_history"`     // 通话状态迁移历史
+}
+
+// ParticipantTrack 成员推流/拉流的track
+type ParticipantTrack struct {
+	SessionId       string   `json:"session_id"`        // track所在会话id
+	RemoteSessionId string   `json:"remote_session_id"` // 拉流track对应的推流会话id
+	Mid             string   `json:"mid"`               // sdp中的mid
+	Kind            string   `json:"kind"`              // audio/video
+	TrackName       string   `json:"track_name"`        // track名
+	Muted           bool     `json:"muted"`             // 是否被静音
+	PreferredRid    string   `json:"preferred_rid"`     // 拉流track优先的simulcast层
+	Rids            []string `json:"rids"`              // 推流track声明的simulcast层
+}
+
+// HasRid 推流track是否声明了该simulcast层
+func (r *ParticipantTrack) HasRid(rid string) bool {
+	for _, id := range r.Rids {
+		if id == rid {
+			return true
+		}
+	}
+	return false
+}
+
+// IsScreenTrack 是否为屏幕共享的track
+func IsScreenTrack(trackName string) bool {
+	return trackName == TrackScreen || trackName == TrackScreenAudio
+}
+
+// AddPublishedKinds 记录推流track的音视频类型, 屏幕共享不计入
+func (r *Participant) AddPublishedKinds(tracks []*ParticipantTrack) {
+	for _, t := range tracks {
+		if t.Kind == "" || IsScreenTrack(t.TrackName) || r.HasPublished(t.Kind) {
+			continue
+		}
+		r.PublishedKinds = append(r.PublishedKinds, t.Kind)
+	}
+}
+
+// HasPublished 通话中是否推流过该类型的track
+func (r *Participant) HasPublished(kind string) bool {
+	for _, k := range r.PublishedKinds {
+		if k == kind {
+			return true
+		}
+	}
+	for _, t := range r.Tracks {
+		if t.Kind == kind && !IsScreenTrack(t.TrackName) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsKicked 成员已被踢出, 解禁后需重新加入或被重新邀请才恢复成员身份
+func (r *Participant) IsKicked() bool {
+	return r.KickTime > 0
+}
+
+// ScreenSessionId 成员正在屏幕共享的会话, 未共享时为空
+func (r *Participant) ScreenSessionId() string {
+	for _, t := range r.Tracks {
+		if t.TrackName == TrackScreen {
+			return t.SessionId
+		}
+	}
+	return ""
+}
+
+// DefaultRole 语音房/视频房成员默认为观众, 通话模式成员默认推流
+func DefaultRole(mode int) int {
+	if IsLiveRoomMode(mode) {
+		return Audience
+	}
+	return Broadcast
+}
+
+// ParticipantDataChannel 成员发布的数据通道
+type ParticipantDataChannel struct {
+	SessionId string `json:"session_id"` // 数据通道所在会话id
+	Name      string `json:"name"`       // 数据通道名
+	Id        int    `json:"id"`         // sfu分配的通道id
+}
+
+// OwnsSession sfu会话是否属于该成员(推流/拉流会话)
+func (r *Participant) OwnsSession(sessionId string) bool {
+	if sessionId == "" {
+		return false
+	}
+	if r.StreamKey == sessionId {
+		return true
+	}
+	for _, t := range r.Tracks {
+		if t.SessionId == sessionId {
+			return true
+		}
+	}
+	for _, t := range r.Subscriptions {
+		if t.SessionId == sessionId {
+			return true
+		}
+	}
+	for _, c := range r.DataChannels {
+		if c.SessionId == sessionId {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMuted 成员该类型的track是否被静音
+func (r *Participant) IsMuted(kind string) bool {
+	if kind == TrackKindAudio {
+		return r.AudioMuted
+	}
+	return r.VideoMuted
+}
+
+// SetMuted 设置成员该类型的静音状态, 并同步到已推流的track上, 返回受影响的track
+func (r *Participant) SetMuted(kind string, muted bool) []*ParticipantTrack {
+	if kind == TrackKindAudio {
+		r.AudioMuted = muted
+	} else {
+		r.VideoMuted = muted
+	}
+	tracks := make([]*ParticipantTrack, 0)
+	for _, t := range r.Tracks {
+		if t.Kind == kind {
+			t.Muted = muted
+			tracks = append(tracks, t)
+		}
+	}
+	return tracks
 }
 
 func (r *Participant) Json() (string, error) {
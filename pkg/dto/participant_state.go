@@ -0,0 +1,105 @@
+package dto
+
+const (
+	CallStateNone     = 0 // 房间创建者或自行加入的成员, 还未加入
+	CallStateInvited  = 1 // 已邀请, 呼叫信令还未送达
+	CallStateRinging  = 2 // 响铃中
+	CallStateAccepted = 3 // 已接听
+	CallStateDeclined = 4 // 已拒绝
+	CallStateTimeout  = 5 // 超时未接听
+	CallStateCanceled = 6 // 未接听前呼叫被取消或房间结束
+	CallStateLeft     = 7 // 已离开
+	CallStateKicked   = 8 // 被踢出
+
+	EndReasonHangup     = "hangup"      // 主动挂断
+	EndReasonDeclined   = "declined"    // 拒绝接听
+	EndReasonBusy       = "busy"        // 通话中拒绝
+	EndReasonTimeout    = "timeout"     // 超时未接听
+	EndReasonCanceled   = "canceled"    // 呼叫被取消
+	EndReasonKicked     = "kicked"      // 被踢出
+	EndReasonNetwork    = "network"     // 心跳超时
+	EndReasonRoomEnded  = "room_ended"  // 房间结束
+	EndReasonOwnerEnded = "owner_ended" // 房主结束通话
+
+	maxStateHistory = 32
+)
+
+// ParticipantState 成员通话状态迁移记录
+type ParticipantState struct {
+	State  int    `json:"state"`
+	Time   int64  `json:"time"`
+	Reason string `json:"reason"`
+}
+
+// callStateTransitions 成员通话状态允许的迁移, 结束状态可以被重新邀请或重新加入
+var callStateTransitions = map[int][]int{
+	CallStateNone:     {CallStateInvited, CallStateAccepted},
+	CallStateInvited:  {CallStateRinging, CallStateAccepted, CallStateDeclined, CallStateTimeout, CallStateCanceled, CallStateKicked},
+	CallStateRinging:  {CallStateAccepted, CallStateDeclined, CallStateTimeout, CallStateCanceled, CallStateKicked},
+	CallStateAccepted: {CallStateLeft, CallStateKicked},
+	CallStateDeclined: {CallStateInvited, CallStateAccepted},
+	CallStateTimeout:  {CallStateInvited, CallStateAccepted},
+	CallStateCanceled: {CallStateInvited, CallStateAccepted},
+	CallStateLeft:     {CallStateInvited, CallStateAccepted},
+	CallStateKicked:   {CallStateInvited, CallStateAccepted},
+}
+
+// CanTransitState 成员能否从当前通话状态迁移到state
+func (r *Participant) CanTransitState(state int) bool {
+	for _, next := range callStateTransitions[r.State] {
+		if next == state {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitState 校验并迁移成员通话状态, 同时记录迁移历史, 不允许的迁移返回false
+func (r *Participant) TransitState(state int, time int64, reason string) bool {
+	if !r.CanTransitState(state) {
+		return false
+	}
+	r.State = state
+	switch state {
+	case CallStateInvited:
+		r.InviteTime, r.RingTime, r.EndReason = time, 0, ""
+	case CallStateRinging:
+		r.RingTime = time
+	case CallStateAccepted:
+		if r.AnswerTime == 0 {
+			r.AnswerTime = time
+		}
+		r.EndReason = ""
+	default:
+		r.EndReason = reason
+	}
+	r.StateHistory = append(r.StateHistory, &ParticipantState{State: state, Time: time, Reason: reason})
+	if len(r.StateHistory) > maxStateHistory {
+		r.StateHistory = r.StateHistory[len(r.StateHistory)-maxStateHistory:]
+	}
+	return true
+}
+
+// CallDuration 成员累计通话时长(ms), 按接听到离开的区间累加, 未离开的计算到endTime
+func (r *Participant) CallDuration(endTime int64) int64 {
+	duration, start := int64(0), int64(0)
+	for _, h := range r.StateHistory {
+		if h.State == CallStateAccepted {
+			if start == 0 {
+				start = h.Time
+			}
+		} else if start > 0 {
+			duration += h.Time - start
+			start = 0
+		}
+	}
+	if start > 0 && endTime > start {
+		duration += endTime - start
+	}
+	return duration
+}
+
+// IsRinging 成员是否已被呼叫但还未应答
+func (r *Participant) IsRinging() bool {
+	return r.State == CallStateInvited || r.State == CallStateRinging
+}
@@ -0,0 +1,59 @@
+package dto
+
+import "testing"
+
+func TestParticipantTransitState(t *testing.T) {
+	cases := []struct {
+		from, to int
+		want     bool
+	}{
+		{CallStateNone, CallStateInvited, true},
+		{CallStateNone, CallStateAccepted, true},
+		{CallStateNone, CallStateRinging, false},
+		{CallStateInvited, CallStateRinging, true},
+		{CallStateInvited, CallStateLeft, false},
+		{CallStateRinging, CallStateTimeout, true},
+		{CallStateRinging, CallStateInvited, false},
+		{CallStateAccepted, CallStateLeft, true},
+		{CallStateAccepted, CallStateDeclined, false},
+		{CallStateAccepted, CallStateTimeout, false},
+		{CallStateDeclined, CallStateInvited, true},
+		{CallStateTimeout, CallStateRinging, false},
+		{CallStateLeft, CallStateAccepted, true},
+		{CallStateKicked, CallStateLeft, false},
+	}
+	for _, c := range cases {
+		p := &Participant{State: c.from}
+		if got := p.TransitState(c.to, 1, ""); got != c.want {
+			t.Errorf("%d -> %d got %v, want %v", c.from, c.to, got, c.want)
+			continue
+		}
+		if !c.want && (p.State != c.from || len(p.StateHistory) != 0) {
+			t.Errorf("%d -> %d rejected but state changed to %d", c.from, c.to, p.State)
+		}
+	}
+}
+
+func TestParticipantStateHistory(t *testing.T) {
+	p := &Participant{}
+	p.TransitState(CallStateInvited, 1, "")
+	p.TransitState(CallStateRinging, 2, "")
+	p.TransitState(CallStateAccepted, 3, "")
+	p.TransitState(CallStateLeft, 10, EndReasonHangup)
+	p.TransitState(CallStateAccepted, 20, "")
+	if p.AnswerTime != 3 || p.EndReason != "" || len(p.StateHistory) != 5 {
+		t.Fatalf("unexpected participant %+v", p)
+	}
+	// 两次接听区间累加: 3~10, 20~25
+	if duration := p.CallDuration(25); duration != 12 {
+		t.Fatalf("duration %d, want 12", duration)
+	}
+
+	for i := 0; i < maxStateHistory; i++ {
+		p.TransitState(CallStateLeft, int64(30+2*i), EndReasonNetwork)
+		p.TransitState(CallStateAccepted, int64(31+2*i), "")
+	}
+	if len(p.StateHistory) != maxStateHistory {
+		t.Fatalf("history %d, want %d", len(p.StateHistory), maxStateHistory)
+	}
+}
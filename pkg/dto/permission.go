@@ -0,0 +1,60 @@
+package dto
+
+// 成员在房间内的权限角色, 与推流角色(Audience/Broadcast)相互独立
+const (
+	RoomRoleMember    = 0 // 普通成员
+	RoomRoleModerator = 1 // 管理员(联席主持)
+	RoomRoleOwner     = 2 // 房主
+)
+
+// 房间操作权限
+const (
+	CapInvite          = 1 << iota // 邀请/呼叫成员
+	CapKick                        // 踢出成员
+	CapEndRoom                     // 结束房间
+	CapMuteOthers                  // 静音其他成员
+	CapPromote                     // 上麦/下麦, 同意/收回发言
+	CapAssignModerator             // 设置/取消管理员
+)
+
+const (
+	ownerCapabilities     = CapInvite | CapKick | CapEndRoom | CapMuteOthers | CapPromote | CapAssignModerator
+	moderatorCapabilities = CapInvite | CapKick | CapMuteOthers | CapPromote
+)
+
+// RoomRoleCapabilities 房间角色拥有的权限
+func RoomRoleCapabilities(mode, roomRole int) int {
+	switch roomRole {
+	case RoomRoleOwner:
+		return ownerCapabilities
+	case RoomRoleModerator:
+		return moderatorCapabilities
+	}
+	// 一对一通话双方对等, 任意一方都可以结束通话
+	if mode == ModeAudio || mode == ModeVideo {
+		return CapEndRoom
+	}
+	return 0
+}
+
+// RoomRoleOf 用户在房间内的权限角色, 非房间成员返回-1
+func (r *Room) RoomRoleOf(uId int64) int {
+	if r.OwnerId == uId {
+		return RoomRoleOwner
+	}
+	for _, p := range r.Participants {
+		if p.UId == uId && !p.IsKicked() {
+			return p.RoomRole
+		}
+	}
+	return -1
+}
+
+// HasCapability 用户在房间内是否拥有指定权限
+func (r *Room) HasCapability(uId int64, capability int) bool {
+	roomRole := r.RoomRoleOf(uId)
+	if roomRole < 0 {
+		return false
+	}
+	return RoomRoleCapabilities(r.Mode, roomRole)&capability != 0
+}
@@ -0,0 +1,58 @@
+package dto
+
+import "testing"
+
+func TestRoomRoleCapabilities(t *testing.T) {
+	cases := []struct {
+		name     string
+		mode     int
+		roomRole int
+		want     int
+	}{
+		{"owner", ModeVoiceRoom, RoomRoleOwner, ownerCapabilities},
+		{"moderator", ModeVideoRoom, RoomRoleModerator, moderatorCapabilities},
+		{"member voice room", ModeVoiceRoom, RoomRoleMember, 0},
+		{"member group chat", ModeChat, RoomRoleMember, 0},
+		{"member audio call", ModeAudio, RoomRoleMember, CapEndRoom},
+		{"member video call", ModeVideo, RoomRoleMember, CapEndRoom},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := RoomRoleCapabilities(c.mode, c.roomRole); got != c.want {
+				t.Fatalf("capabilities %b, want %b", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRoomHasCapability(t *testing.T) {
+	room := &Room{
+		Mode:    ModeVoiceRoom,
+		OwnerId: 1,
+		Participants: []*Participant{
+			{UId: 1, RoomRole: RoomRoleOwner},
+			{UId: 2, RoomRole: RoomRoleModerator},
+			{UId: 3, RoomRole: RoomRoleMember},
+			{UId: 4, RoomRole: RoomRoleModerator, KickTime: 1},
+		},
+	}
+	cases := []struct {
+		uId        int64
+		capability int
+		want       bool
+	}{
+		{1, CapEndRoom, true},
+		{1, CapAssignModerator, true},
+		{2, CapKick, true},
+		{2, CapEndRoom, false},
+		{2, CapAssignModerator, false},
+		{3, CapInvite, false},
+		{4, CapKick, false},
+		{5, CapInvite, false},
+	}
+	for _, c := range cases {
+		if got := room.HasCapability(c.uId, c.capability); got != c.want {
+			t.Errorf("user %d capability %b got %v, want %v", c.uId, c.capability, got, c.want)
+		}
+	}
+}
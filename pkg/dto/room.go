@@ -4,26 +4,46 @@ import "encoding/json"
 
 type (
 	MediaParams struct {
-		VideoMaxBitrate int `json:"video_max_bitrate"` // 视频最大码率
-		VideoWidth      int `json:"video_width"`       // 视频分辨率宽
-		VideoHeight     int `json:"video_height"`      // 视频分辨率高
-		VideoFps        int `json:"video_fps"`         // 视频每秒帧
-		AudioMaxBitrate int `json:"audio_max_bitrate"` // 音频最大码率
+		VideoMaxBitrate int `json:"video_max_bitrate,omitempty"` // 视频最大码率, 语音模式不下发视频参数
+		VideoWidth      int `json:"video_width,omitempty"`       // 视频分辨率宽
+		VideoHeight     int `json:"video_height,omitempty"`      // 视频分辨率高
+		VideoFps        int `json:"video_fps,omitempty"`         // 视频每秒帧
+		AudioMaxBitrate int `json:"audio_max_bitrate"`           // 音频最大码率
 	}
 
 	RoomCreateReq struct {
 		UId         int64        `json:"u_id"`
 		Mode        int          `json:"mode"`       // 1普通聊天 2语音电话 3视频电话 4语音房 5视频房
 		SessionId   int64        `json:"session_id"` // 会话id
+		Engine      string       `json:"engine"`     // RTC引擎, 为空时使用部署默认引擎
 		MediaParams *MediaParams `json:"media_params"`
 	}
 
 	RoomCallReq struct {
-		UId      int64   `json:"u_id"`
-		RoomId   string  `json:"room_id"`
-		Msg      string  `json:"msg"`
-		Members  []int64 `json:"members"`
-		Duration int64   `json:"duration"` // 单位s
+		UId         int64   `json:"u_id"`
+		RoomId      string  `json:"room_id"`
+		Msg         string  `json:"msg"`
+		Members     []int64 `json:"members"`
+		Duration    int64   `json:"duration"`     // 单位s
+		CallWaiting bool    `json:"call_waiting"` // 被叫通话中时推送呼叫等待, 否则直接按忙线拒绝
+	}
+
+	// CallMemberResult 呼叫/邀请单个成员的结果
+	CallMemberResult struct {
+		UId        int64  `json:"u_id"`
+		Busy       bool   `json:"busy"`         // 是否在其他通话中
+		BusyRoomId string `json:"busy_room_id"` // 所在的其他房间
+		Waiting    bool   `json:"waiting"`      // 已推送呼叫等待
+	}
+
+	CallMembersResp struct {
+		Results []*CallMemberResult `json:"results"`
+	}
+
+	UserStatusResp struct {
+		UId    int64  `json:"u_id"`
+		Busy   bool   `json:"busy"`
+		RoomId string `json:"room_id"` // 当前所在房间, 不在通话中为空
 	}
 
 	CancelCallingReq struct {
@@ -57,24 +77,54 @@ type (
 	}
 
 	InviteJoinRoomReq struct {
-		UId        int64   `json:"u_id"`
-		InviteUIds []int64 `json:"invite_u_ids"`
-		RoomId     string  `json:"room_id"`
-		Msg        string  `json:"msg"`
-		Duration   int64   `json:"duration"` // 单位s
+		UId         int64   `json:"u_id"`
+		InviteUIds  []int64 `json:"invite_u_ids"`
+		RoomId      string  `json:"room_id"`
+		Msg         string  `json:"msg"`
+		Duration    int64   `json:"duration"`     // 单位s
+		CallWaiting bool    `json:"call_waiting"` // 被邀请人通话中时推送呼叫等待, 否则直接按忙线拒绝
 	}
 
 	RoomMemberLeaveReq struct {
+		UId        int64  `json:"u_id"`
+		RoomId     string `json:"room_id"`
+		Msg        string `json:"msg"`
+		NewOwnerId int64  `json:"new_owner_id"` // 房主离开时指定的新房主, 为0时自动选择
+	}
+
+	MemberHeartbeatReq struct {
+		UId    int64  `json:"u_id"`
+		RoomId string `json:"room_id"`
+	}
+
+	HandReq struct {
 		UId    int64  `json:"u_id"`
 		RoomId string `json:"room_id"`
 		Msg    string `json:"msg"`
 	}
 
+	MemberRoleReq struct {
+		UId       int64  `json:"u_id"`
+		RoomId    string `json:"room_id"`
+		MemberUId int64  `json:"member_u_id"`
+		Msg       string `json:"msg"`
+	}
+
+	MuteMemberReq struct {
+		UId       int64  `json:"u_id"`
+		RoomId    string `json:"room_id"`
+		MemberUId int64  `json:"member_u_id"`
+		Kind      string `json:"kind"`  // audio/video
+		Muted     bool   `json:"muted"` // true静音 false取消静音
+		Msg       string `json:"msg"`
+	}
+
 	KickoffMemberReq struct {
 		UId         int64   `json:"u_id"`
 		RoomId      string  `json:"room_id"`
 		Msg         string  `json:"msg"`
 		KickoffUIds []int64 `json:"kickoff_u_ids"`
+		BanDuration int64   `json:"ban_duration"` // 禁止再次加入的时长, 单位s, 0表示房间结束前不可再加入
 	}
 )
 
@@ -85,10 +135,35 @@ const (
 	ModeVoiceRoom = 4
 	ModeVideoRoom = 5
 
-	RoomStatusInit   = 0 // 初始化状态
-	RoomStatusActive = 1 // 有人加入
+	RoomStatusRinging = 0 // 呼叫中, 还没有成员接听
+	RoomStatusActive  = 1 // 通话中
+	RoomStatusEnding  = 2 // 结束中, 正在清理房间资源
+	RoomStatusEnded   = 3 // 已结束
+
+	EngineWebRTC        = "WebRTC"        // thk-im-rtc-server
+	EngineCloudflareSFU = "CloudflareSFU" // Cloudflare Calls
 )
 
+// VideoEnable 房间是否推视频, 语音模式视频码率为0
+func (r *MediaParams) VideoEnable() bool {
+	return r != nil && r.VideoMaxBitrate > 0
+}
+
+// AudioEnable 房间是否推音频
+func (r *MediaParams) AudioEnable() bool {
+	return r != nil && r.AudioMaxBitrate > 0
+}
+
+// IsAudioMode 语音电话/语音房只有音频
+func IsAudioMode(mode int) bool {
+	return mode == ModeAudio || mode == ModeVoiceRoom
+}
+
+// IsLiveRoomMode 语音房/视频房区分观众和推流者
+func IsLiveRoomMode(mode int) bool {
+	return mode == ModeVoiceRoom || mode == ModeVideoRoom
+}
+
 // Room 房间
 type Room struct {
 	Id           string         `json:"id"`           // 房间id
@@ -98,9 +173,36 @@ type Room struct {
 	CreateTime   int64          `json:"create_time"`  // 房间创建时间
 	SessionId    *int64         `json:"session_id"`   // sessionId
 	MediaParams  *MediaParams   `json:"media_params"` // 媒体参数
+	Status       int            `json:"status"`       // 房间状态, 只能通过roomService.transitRoomStatus修改
+	StatusTime   int64          `json:"status_time"`  // 状态变更时间
+	EndUId       int64          `json:"end_u_id"`     // 结束通话的用户, 0为系统结束
+	EndReason    string         `json:"end_reason"`   // 通话结束原因, 见EndReason*
+	PeakCount    int            `json:"peak_count"`   // 同时在通话中的最大人数
 	Participants []*Participant `json:"participants"` // 房间实际参与人
 }
 
+// roomStatusTransitions 房间状态允许的迁移
+var roomStatusTransitions = map[int][]int{
+	RoomStatusRinging: {RoomStatusActive, RoomStatusEnding},
+	RoomStatusActive:  {RoomStatusEnding},
+	RoomStatusEnding:  {RoomStatusEnded},
+}
+
+// CanTransitTo 房间能否从当前状态迁移到status
+func (r *Room) CanTransitTo(status int) bool {
+	for _, next := range roomStatusTransitions[r.Status] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEnded 房间已结束或正在结束
+func (r *Room) IsEnded() bool {
+	return r.Status == RoomStatusEnding || r.Status == RoomStatusEnded
+}
+
 func (r *Room) Json() (string, error) {
 	b, err := json.Marshal(r)
 	if err != nil {
@@ -109,8 +211,9 @@ func (r *Room) Json() (string, error) {
 	return string(b), err
 }
 
+// NewRoomByJson 旧版本缓存的房间没有status字段, 按通话中处理, 避免零值被当作呼叫中
 func NewRoomByJson(b []byte) (*Room, error) {
-	room := &Room{}
+	room := &Room{Status: RoomStatusActive}
 	err := json.Unmarshal(b, room)
 	return room, err
 }
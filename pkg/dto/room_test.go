@@ -0,0 +1,63 @@
+package dto
+
+import "testing"
+
+func TestRoomCanTransitTo(t *testing.T) {
+	cases := []struct {
+		from, to int
+		want     bool
+	}{
+		{RoomStatusRinging, RoomStatusActive, true},
+		{RoomStatusRinging, RoomStatusEnding, true},
+		{RoomStatusRinging, RoomStatusEnded, false},
+		{RoomStatusActive, RoomStatusEnding, true},
+		{RoomStatusActive, RoomStatusRinging, false},
+		{RoomStatusActive, RoomStatusEnded, false},
+		{RoomStatusEnding, RoomStatusEnded, true},
+		{RoomStatusEnding, RoomStatusActive, false},
+		{RoomStatusEnded, RoomStatusActive, false},
+		{RoomStatusEnded, RoomStatusEnded, false},
+	}
+	for _, c := range cases {
+		room := &Room{Status: c.from}
+		if got := room.CanTransitTo(c.to); got != c.want {
+			t.Errorf("%d -> %d got %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+}
+
+func TestNewRoomByJsonStatus(t *testing.T) {
+	cases := []struct {
+		name string
+		json string
+		want int
+	}{
+		{"missing status", `{"id":"room","owner_id":1}`, RoomStatusActive},
+		{"ringing", `{"id":"room","status":0}`, RoomStatusRinging},
+		{"ended", `{"id":"room","status":3}`, RoomStatusEnded},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			room, err := NewRoomByJson([]byte(c.json))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if room.Status != c.want {
+				t.Fatalf("status %d, want %d", room.Status, c.want)
+			}
+		})
+	}
+
+	// 新写入的呼叫中房间读取后仍为呼叫中
+	roomJson, err := (&Room{Id: "room", Status: RoomStatusRinging}).Json()
+	if err != nil {
+		t.Fatal(err)
+	}
+	room, err := NewRoomByJson([]byte(roomJson))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room.Status != RoomStatusRinging {
+		t.Fatalf("status %d, want ringing", room.Status)
+	}
+}
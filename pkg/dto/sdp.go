@@ -0,0 +1,119 @@
+package dto
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sdpSection sdp中的会话级或媒体级片段, kind为空时为会话级
+type sdpSection struct {
+	kind  string
+	lines []string
+}
+
+// splitSdp 按m-line拆分sdp, 返回换行符和各片段, 第一个片段为会话级
+func splitSdp(sdp string) (string, []*sdpSection) {
+	sep := "\n"
+	if strings.Contains(sdp, "\r\n") {
+		sep = "\r\n"
+	}
+	sections := []*sdpSection{{}}
+	for _, line := range strings.Split(strings.TrimRight(sdp, "\r\n"), sep) {
+		if strings.HasPrefix(line, "m=") {
+			kind := strings.TrimPrefix(strings.Fields(line)[0], "m=")
+			sections = append(sections, &sdpSection{kind: kind})
+		}
+		current := sections[len(sections)-1]
+		current.lines = append(current.lines, line)
+	}
+	return sep, sections
+}
+
+func joinSdp(sep string, sections []*sdpSection) string {
+	lines := make([]string, 0)
+	for _, s := range sections {
+		lines = append(lines, s.lines...)
+	}
+	return strings.Join(lines, sep) + sep
+}
+
+// removeLines 删除以prefixes开头的行
+func (s *sdpSection) removeLines(prefixes ...string) {
+	lines := make([]string, 0, len(s.lines))
+	for _, line := range s.lines {
+		removed := false
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(line, prefix) {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			lines = append(lines, line)
+		}
+	}
+	s.lines = lines
+}
+
+// insertBandwidth 按sdp字段顺序(m i c b a)在c=或m=之后写入b=行
+func (s *sdpSection) insertBandwidth(lines ...string) {
+	index := 1
+	for i, line := range s.lines {
+		if strings.HasPrefix(line, "i=") || strings.HasPrefix(line, "c=") {
+			index = i + 1
+		}
+	}
+	result := make([]string, 0, len(s.lines)+len(lines))
+	result = append(result, s.lines[:index]...)
+	result = append(result, lines...)
+	result = append(result, s.lines[index:]...)
+	s.lines = result
+}
+
+// LimitSdpMedia 在推流answer的音视频m-line中写入房间媒体参数: 码率(b=AS/b=TIAS), 视频帧率(a=framerate)和分辨率(a=imageattr),
+// 客户端按answer限制发送的码率和画面
+func LimitSdpMedia(sdp string, params *MediaParams) string {
+	if sdp == "" || params == nil {
+		return sdp
+	}
+	sep, sections := splitSdp(sdp)
+	for _, s := range sections {
+		bitrate := 0
+		switch s.kind {
+		case TrackKindAudio:
+			bitrate = params.AudioMaxBitrate
+		case TrackKindVideo:
+			bitrate = params.VideoMaxBitrate
+			if params.VideoFps > 0 {
+				s.removeLines("a=framerate:")
+				s.lines = append(s.lines, fmt.Sprintf("a=framerate:%d", params.VideoFps))
+			}
+			if params.VideoWidth > 0 && params.VideoHeight > 0 {
+				s.removeLines("a=imageattr:")
+				s.lines = append(s.lines, fmt.Sprintf("a=imageattr:* recv [x=[1:%d],y=[1:%d]]", params.VideoWidth, params.VideoHeight))
+			}
+		default:
+			continue
+		}
+		if bitrate > 0 {
+			s.removeLines("b=AS:", "b=TIAS:")
+			s.insertBandwidth(fmt.Sprintf("b=AS:%d", (bitrate+999)/1000), fmt.Sprintf("b=TIAS:%d", bitrate))
+		}
+	}
+	return joinSdp(sep, sections)
+}
+
+// DisableSdpMedia 将房间媒体参数未开启的音视频m-line改为inactive, 使answer与实际推流的track一致
+func DisableSdpMedia(sdp string, params *MediaParams) string {
+	if sdp == "" {
+		return sdp
+	}
+	sep, sections := splitSdp(sdp)
+	for _, s := range sections {
+		if s.kind == TrackKindAudio && !params.AudioEnable() || s.kind == TrackKindVideo && !params.VideoEnable() {
+			s.removeLines("a=sendrecv", "a=sendonly", "a=recvonly", "a=inactive")
+			s.lines = append(s.lines, "a=inactive")
+		}
+	}
+	return joinSdp(sep, sections)
+}
@@ -0,0 +1,96 @@
+package dto
+
+import (
+	"strings"
+	"testing"
+)
+
+const testAnswerSdp = "v=0\r\n" +
+	"o=- 1 2 IN IP4 127.0.0.1\r\n" +
+	"s=-\r\n" +
+	"t=0 0\r\n" +
+	"a=group:BUNDLE 0 1\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"b=AS:500\r\n" +
+	"a=mid:0\r\n" +
+	"a=recvonly\r\n" +
+	"m=video 9 UDP/TLS/RTP/SAVPF 96\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=mid:1\r\n" +
+	"a=recvonly\r\n"
+
+// sdpSectionLines 返回mid所在m-line片段的行
+func sdpSectionLines(sdp, mid string) []string {
+	_, sections := splitSdp(sdp)
+	for _, s := range sections {
+		for _, line := range s.lines {
+			if line == "a=mid:"+mid {
+				return s.lines
+			}
+		}
+	}
+	return nil
+}
+
+func TestLimitSdpMedia(t *testing.T) {
+	params := &MediaParams{VideoMaxBitrate: 1500000, VideoWidth: 1280, VideoHeight: 720, VideoFps: 30, AudioMaxBitrate: 64000}
+	sdp := LimitSdpMedia(testAnswerSdp, params)
+	if !strings.HasSuffix(sdp, "\r\n") || strings.Contains(strings.ReplaceAll(sdp, "\r\n", ""), "\n") {
+		t.Fatalf("line ending changed %q", sdp)
+	}
+
+	audio := strings.Join(sdpSectionLines(sdp, "0"), "\n")
+	want := "m=audio 9 UDP/TLS/RTP/SAVPF 111\nc=IN IP4 0.0.0.0\nb=AS:64\nb=TIAS:64000\na=mid:0\na=recvonly"
+	if audio != want {
+		t.Fatalf("audio section\n%s\nwant\n%s", audio, want)
+	}
+	video := strings.Join(sdpSectionLines(sdp, "1"), "\n")
+	want = "m=video 9 UDP/TLS/RTP/SAVPF 96\nc=IN IP4 0.0.0.0\nb=AS:1500\nb=TIAS:1500000\na=mid:1\na=recvonly\n" +
+		"a=framerate:30\na=imageattr:* recv [x=[1:1280],y=[1:720]]"
+	if video != want {
+		t.Fatalf("video section\n%s\nwant\n%s", video, want)
+	}
+	// 重复写入不会产生重复的行
+	if again := LimitSdpMedia(sdp, params); again != sdp {
+		t.Fatalf("not idempotent\n%s", again)
+	}
+}
+
+func TestLimitSdpMediaSkip(t *testing.T) {
+	cases := []struct {
+		name   string
+		sdp    string
+		params *MediaParams
+	}{
+		{"empty sdp", "", &MediaParams{AudioMaxBitrate: 64000}},
+		{"nil params", testAnswerSdp, nil},
+		{"zero params", testAnswerSdp, &MediaParams{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if sdp := LimitSdpMedia(c.sdp, c.params); sdp != c.sdp {
+				t.Fatalf("sdp changed\n%s", sdp)
+			}
+		})
+	}
+}
+
+func TestDisableSdpMedia(t *testing.T) {
+	offer := strings.ReplaceAll(testAnswerSdp, "a=recvonly", "a=sendonly")
+	sdp := DisableSdpMedia(offer, &MediaParams{AudioMaxBitrate: 64000})
+
+	audio := strings.Join(sdpSectionLines(sdp, "0"), "\n")
+	if !strings.HasSuffix(audio, "a=sendonly") || strings.Contains(audio, "a=inactive") {
+		t.Fatalf("audio section changed\n%s", audio)
+	}
+	video := strings.Join(sdpSectionLines(sdp, "1"), "\n")
+	if strings.Contains(video, "a=sendonly") || !strings.HasSuffix(video, "a=inactive") {
+		t.Fatalf("video section not inactive\n%s", video)
+	}
+	// m-line和mid保持不变, answer仍与offer一一对应
+	tracks := ParseTracksFromSDP(sdp, "")
+	if len(tracks) != 2 || tracks[0].Mid != "0" || tracks[1].Mid != "1" {
+		t.Fatalf("unexpected tracks %+v", tracks)
+	}
+}
@@ -25,6 +25,36 @@ const (
 	ParticipantStartPush = 8
 	// ParticipantStopPush 成员停止推流
 	ParticipantStopPush = 9
+	// NoAnswer 被请求人超时未接听
+	NoAnswer = 10
+	// MemberRoleChanged 成员角色变更
+	MemberRoleChanged = 11
+	// HandRaised 观众举手申请发言
+	HandRaised = 12
+	// HandLowered 观众取消举手
+	HandLowered = 13
+	// SpeakGranted 房主同意发言
+	SpeakGranted = 14
+	// SpeakRevoked 房主拒绝或收回发言
+	SpeakRevoked = 15
+	// RoomRoleChanged 成员被设置/取消管理员
+	RoomRoleChanged = 16
+	// OwnerChanged 房主变更
+	OwnerChanged = 17
+	// MemberMuted 成员被静音/取消静音
+	MemberMuted = 18
+	// DataChannelPublished 成员发布数据通道
+	DataChannelPublished = 19
+	// DataChannelClosed 成员关闭数据通道
+	DataChannelClosed = 20
+	// ScreenShareStarted 成员开始屏幕共享
+	ScreenShareStarted = 21
+	// ScreenShareStopped 成员停止屏幕共享
+	ScreenShareStopped = 22
+	// CallWaiting 被请求人正在其他通话中, 呼叫等待
+	CallWaiting = 23
+	// RoomStatusChanged 房间状态变更
+	RoomStatusChanged = 24
 )
 
 type (
@@ -99,6 +129,77 @@ type (
 		StreamKey string `json:"stream_key"`
 		Time      int64  `json:"time"`
 	}
+
+	MemberRoleChangedSignal struct {
+		RoomId    string `json:"room_id"`
+		UId       int64  `json:"u_id"`
+		MemberUId int64  `json:"member_u_id"`
+		Role      int    `json:"role"`
+		Msg       string `json:"msg"`
+		Time      int64  `json:"time"`
+	}
+
+	RoomRoleChangedSignal struct {
+		RoomId    string `json:"room_id"`
+		UId       int64  `json:"u_id"`
+		MemberUId int64  `json:"member_u_id"`
+		RoomRole  int    `json:"room_role"`
+		Msg       string `json:"msg"`
+		Time      int64  `json:"time"`
+	}
+
+	OwnerChangedSignal struct {
+		RoomId     string `json:"room_id"`
+		OldOwnerId int64  `json:"old_owner_id"`
+		NewOwnerId int64  `json:"new_owner_id"`
+		Time       int64  `json:"time"`
+	}
+
+	RoomStatusChangedSignal struct {
+		RoomId    string `json:"room_id"`
+		OldStatus int    `json:"old_status"`
+		Status    int    `json:"status"`
+		Time      int64  `json:"time"`
+	}
+
+	MemberMutedSignal struct {
+		RoomId    string `json:"room_id"`
+		UId       int64  `json:"u_id"`
+		MemberUId int64  `json:"member_u_id"`
+		Kind      string `json:"kind"`
+		Muted     bool   `json:"muted"`
+		Msg       string `json:"msg"`
+		Time      int64  `json:"time"`
+	}
+
+	DataChannelSignal struct {
+		RoomId    string `json:"room_id"`
+		UId       int64  `json:"u_id"`
+		SessionId string `json:"session_id"`
+		Name      string `json:"name"`
+		Time      int64  `json:"time"`
+	}
+
+	ScreenShareSignal struct {
+		RoomId    string `json:"room_id"`
+		UId       int64  `json:"u_id"`
+		SessionId string `json:"session_id"`
+		Time      int64  `json:"time"`
+	}
+
+	SpeakSignal struct {
+		RoomId    string `json:"room_id"`
+		UId       int64  `json:"u_id"`
+		MemberUId int64  `json:"member_u_id"`
+		Msg       string `json:"msg"`
+		Time      int64  `json:"time"`
+	}
+
+	NoAnswerSignal struct {
+		RoomId      string `json:"room_id"`
+		UId         int64  `json:"u_id"`
+		TimeoutTime int64  `json:"timeout_time"`
+	}
 )
 
 func MakeBeingRequestedSignal(roomId string, members []int64, mode int, msg string, uId, createTime, timeoutTime int64) *LiveCallSignal {
@@ -118,6 +219,16 @@ func MakeBeingRequestedSignal(roomId string, members []int64, mode int, msg stri
 	return &LiveCallSignal{Type: BeingRequested, Body: string(signalJson)}
 }
 
+// MakeCallWaitingSignal 呼叫等待信令, 内容与BeingRequested一致
+func MakeCallWaitingSignal(roomId string, members []int64, mode int, msg string, uId, createTime, timeoutTime int64) *LiveCallSignal {
+	signal := MakeBeingRequestedSignal(roomId, members, mode, msg, uId, createTime, timeoutTime)
+	if signal == nil {
+		return nil
+	}
+	signal.Type = CallWaiting
+	return signal
+}
+
 func MakeCancelRequestingSignal(roomId string, msg string, createTime, cancelTime int64) *LiveCallSignal {
 	signal := &CancelRequestedSignal{
 		RoomId:     roomId,
@@ -231,6 +342,128 @@ func MakeParticipantLeaveSignal(roomId string, streamKey string, uId, time int64
 	return &LiveCallSignal{Type: ParticipantStopPush, Body: string(signalJson)}
 }
 
+func MakeNoAnswerSignal(roomId string, uId, timeoutTime int64) *LiveCallSignal {
+	signal := &NoAnswerSignal{
+		RoomId:      roomId,
+		UId:         uId,
+		TimeoutTime: timeoutTime,
+	}
+	signalJson, err := json.Marshal(signal)
+	if err != nil {
+		return nil
+	}
+	return &LiveCallSignal{Type: NoAnswer, Body: string(signalJson)}
+}
+
+func MakeMemberRoleChangedSignal(roomId string, msg string, uId, memberUId int64, role int, time int64) *LiveCallSignal {
+	signal := &MemberRoleChangedSignal{
+		RoomId:    roomId,
+		UId:       uId,
+		MemberUId: memberUId,
+		Role:      role,
+		Msg:       msg,
+		Time:      time,
+	}
+	signalJson, err := json.Marshal(signal)
+	if err != nil {
+		return nil
+	}
+	return &LiveCallSignal{Type: MemberRoleChanged, Body: string(signalJson)}
+}
+
+// MakeSpeakSignal 举手发言相关信令, signalType为HandRaised/HandLowered/SpeakGranted/SpeakRevoked
+func MakeSpeakSignal(signalType int, roomId string, msg string, uId, memberUId, time int64) *LiveCallSignal {
+	signal := &SpeakSignal{
+		RoomId:    roomId,
+		UId:       uId,
+		MemberUId: memberUId,
+		Msg:       msg,
+		Time:      time,
+	}
+	signalJson, err := json.Marshal(signal)
+	if err != nil {
+		return nil
+	}
+	return &LiveCallSignal{Type: signalType, Body: string(signalJson)}
+}
+
+func MakeRoomRoleChangedSignal(roomId string, msg string, uId, memberUId int64, roomRole int, time int64) *LiveCallSignal {
+	signal := &RoomRoleChangedSignal{
+		RoomId:    roomId,
+		UId:       uId,
+		MemberUId: memberUId,
+		RoomRole:  roomRole,
+		Msg:       msg,
+		Time:      time,
+	}
+	signalJson, err := json.Marshal(signal)
+	if err != nil {
+		return nil
+	}
+	return &LiveCallSignal{Type: RoomRoleChanged, Body: string(signalJson)}
+}
+
+func MakeOwnerChangedSignal(roomId string, oldOwnerId, newOwnerId, time int64) *LiveCallSignal {
+	signal := &OwnerChangedSignal{
+		RoomId:     roomId,
+		OldOwnerId: oldOwnerId,
+		NewOwnerId: newOwnerId,
+		Time:       time,
+	}
+	signalJson, err := json.Marshal(signal)
+	if err != nil {
+		return nil
+	}
+	return &LiveCallSignal{Type: OwnerChanged, Body: string(signalJson)}
+}
+
+func MakeRoomStatusChangedSignal(roomId string, oldStatus, status int, time int64) *LiveCallSignal {
+	signal := &RoomStatusChangedSignal{
+		RoomId:    roomId,
+		OldStatus: oldStatus,
+		Status:    status,
+		Time:      time,
+	}
+	signalJson, err := json.Marshal(signal)
+	if err != nil {
+		return nil
+	}
+	return &LiveCallSignal{Type: RoomStatusChanged, Body: string(signalJson)}
+}
+
+func MakeMemberMutedSignal(roomId string, msg string, uId, memberUId int64, kind string, muted bool, time int64) *LiveCallSignal {
+	signal := &MemberMutedSignal{
+		RoomId:    roomId,
+		UId:       uId,
+		MemberUId: memberUId,
+		Kind:      kind,
+		Muted:     muted,
+		Msg:       msg,
+		Time:      time,
+	}
+	signalJson, err := json.Marshal(signal)
+	if err != nil {
+		return nil
+	}
+	return &LiveCallSignal{Type: MemberMuted, Body: string(signalJson)}
+}
+
+// MakeDataChannelSignal 数据通道信令, signalType为DataChannelPublished/DataChannelClosed
+func MakeDataChannelSignal(signalType int, roomId string, uId int64, sessionId, name string, time int64) *LiveCallSignal {
+	signal := &DataChannelSignal{
+		RoomId:    roomId,
+		UId:       uId,
+		SessionId: sessionId,
+		Name:      name,
+		Time:      time,
+	}
+	signalJson, err := json.Marshal(signal)
+	if err != nil {
+		return nil
+	}
+	return &LiveCallSignal{Type: signalType, Body: string(signalJson)}
+}
+
 func (l LiveCallSignal) JsonString() string {
 	d, err := json.Marshal(l)
 	if err != nil {
@@ -239,3 +472,18 @@ func (l LiveCallSignal) JsonString() string {
 		return string(d)
 	}
 }
+
+// MakeScreenShareSignal 屏幕共享信令, signalType为ScreenShareStarted/ScreenShareStopped
+func MakeScreenShareSignal(signalType int, roomId string, uId int64, sessionId string, time int64) *LiveCallSignal {
+	signal := &ScreenShareSignal{
+		RoomId:    roomId,
+		UId:       uId,
+		SessionId: sessionId,
+		Time:      time,
+	}
+	signalJson, err := json.Marshal(signal)
+	if err != nil {
+		return nil
+	}
+	return &LiveCallSignal{Type: signalType, Body: string(signalJson)}
+}
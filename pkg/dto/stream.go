@@ -1,34 +1,166 @@
 package dto
 
+const (
+	StreamStatusStart = "start" // 开始推流
+	StreamStatusIng   = "ing"   // 推流中, 作为心跳定时上报
+	StreamStatusStop  = "stop"  // 停止推流
+)
+
 type PublishStreamReq struct {
-	RoomId string `json:"room_id"`
-	Type   string `json:"type"`
-	Sdp    string `json:"sdp"`
-	Uid    int64  `json:"uid"`
+	RoomId   string         `json:"room_id"`
+	Type     string         `json:"type"`
+	Sdp      string         `json:"sdp"`
+	Tracks   []*StreamTrack `json:"tracks"`   // 按mid指定track名, 如screen, 未指定的按sdp中的顺序命名
+	Override bool           `json:"override"` // 管理员在已有人共享屏幕时仍然共享
+	Uid      int64          `json:"uid"`
 }
 
 type PublishStreamResp struct {
+	SessionId   string       `json:"session_id"`
+	Sdp         string       `json:"sdp"`
+	Type        string       `json:"type"`
+	MediaParams *MediaParams `json:"media_params"` // 客户端按该参数设置编码码率/分辨率/帧率
+}
+
+type SubscribeStreamReq struct {
+	RoomId              string         `json:"room_id"`
+	SessionId           string         `json:"session_id"`            // 推流会话id
+	SessionIds          []string       `json:"session_ids"`           // 一次拉取多个推流会话
+	All                 bool           `json:"all"`                   // 拉取房间内当前所有推流会话
+	SubscriberSessionId string         `json:"subscriber_session_id"` // 已有的拉流会话, 为空时新建会话
+	Sdp                 string         `json:"sdp"`
+	Tracks              []*StreamTrack `json:"tracks"`        // 指定拉取的track, 为空时拉取推流会话已发布的全部track
+	TrackNames          []string       `json:"track_names"`   // 只拉取这些名字的track, 如只拉取screen
+	PreferredRid        string         `json:"preferred_rid"` // 视频track优先拉取的simulcast层
+	Uid                 int64          `json:"uid"`
+}
+
+// IsMulti 是否为单会话拉取多个推流者的请求
+func (r *SubscribeStreamReq) IsMulti() bool {
+	return r.All || len(r.SessionIds) > 0 || r.SubscriberSessionId != "" || len(r.Tracks) > 0
+}
+
+type SubscribeStreamResp struct {
+	SessionId     string         `json:"session_id"` // 拉流会话id
+	Renegotiation bool           `json:"renegotiation"`
+	Sdp           string         `json:"sdp"`
+	Type          string         `json:"type"`
+	Tracks        []*StreamTrack `json:"tracks"` // 本次新增的拉流track
+}
+
+// UnsubscribeStreamReq 从拉流会话中移除推流者的track, 如推流者离开房间
+type UnsubscribeStreamReq struct {
+	RoomId           string   `json:"room_id"`
+	SessionId        string   `json:"session_id"`         // 拉流会话id
+	RemoteSessionIds []string `json:"remote_session_ids"` // 为空时移除该会话的全部拉流track
+	Uid              int64    `json:"uid"`
+}
+
+type UnsubscribeStreamResp struct {
+	Mids []string `json:"mids"` // 已关闭的track, 客户端停止对应的transceiver
+}
+
+type StreamStatusUpdateReq struct {
+	RoomId    string `json:"room_id"`
 	SessionId string `json:"session_id"`
+	Status    string `json:"status"` // start/ing/stop
+	Uid       int64  `json:"uid"`
+}
+
+type RenegotiateReq struct {
+	RoomId    string `json:"room_id"`
+	SessionId string `json:"session_id"`
+	Type      string `json:"type"` // 默认answer
 	Sdp       string `json:"sdp"`
-	Type      string `json:"type"`
+	Uid       int64  `json:"uid"`
 }
 
-type SubscribeStreamReq struct {
+type RenegotiateResp struct {
+	Sdp  string `json:"sdp"`
+	Type string `json:"type"`
+}
+
+// StreamTrack 会话内的track, RemoteSessionId非空表示拉取该推流会话的track
+type StreamTrack struct {
+	Mid             string   `json:"mid"`
+	Kind            string   `json:"kind"`
+	TrackName       string   `json:"track_name"`
+	RemoteSessionId string   `json:"remote_session_id"`
+	PreferredRid    string   `json:"preferred_rid"` // simulcast优先拉取的层
+	Rids            []string `json:"rids"`          // 推流track声明的simulcast层, 如high/mid/low
+}
+
+// StreamTracksReq 在已有会话上新增/更新track, 无需重建PeerConnection
+type StreamTracksReq struct {
+	RoomId    string         `json:"room_id"`
+	SessionId string         `json:"session_id"`
+	Sdp       string         `json:"sdp"` // 新增推流track时为offer, 新增时tracks为空则从sdp中解析新的mid
+	Tracks    []*StreamTrack `json:"tracks"`
+	Override  bool           `json:"override"` // 管理员在已有人共享屏幕时仍然共享
+	Uid       int64          `json:"uid"`
+}
+
+type StreamTracksResp struct {
+	Renegotiation bool           `json:"renegotiation"`
+	Sdp           string         `json:"sdp"`
+	Type          string         `json:"type"`
+	Tracks        []*StreamTrack `json:"tracks"`
+}
+
+// SimulcastLayerReq 切换拉流会话中视频track的simulcast层
+type SimulcastLayerReq struct {
+	RoomId          string `json:"room_id"`
+	SessionId       string `json:"session_id"`        // 拉流会话id
+	RemoteSessionId string `json:"remote_session_id"` // 推流会话id
+	TrackName       string `json:"track_name"`        // 为空时切换该推流会话的全部视频track
+	PreferredRid    string `json:"preferred_rid"`
+	Uid             int64  `json:"uid"`
+}
+
+// CloseStreamTracksReq 关闭推流会话中的track, 如停止屏幕共享
+type CloseStreamTracksReq struct {
+	RoomId    string   `json:"room_id"`
+	SessionId string   `json:"session_id"`
+	Mids      []string `json:"mids"`
+	Uid       int64    `json:"uid"`
+}
+
+type DataChannelTransportReq struct {
 	RoomId    string `json:"room_id"`
 	SessionId string `json:"session_id"`
 	Sdp       string `json:"sdp"`
 	Uid       int64  `json:"uid"`
 }
 
-type SubscribeStreamResp struct {
+type DataChannelTransportResp struct {
 	Renegotiation bool   `json:"renegotiation"`
 	Sdp           string `json:"sdp"`
 	Type          string `json:"type"`
 }
 
-type StreamStatusUpdateReq struct {
+type PublishDataChannelReq struct {
 	RoomId    string `json:"room_id"`
-	SessionId string `json:"session_id"`
-	Status    string `json:"status"` // begin/ing/end
+	SessionId string `json:"session_id"` // 自己的推流/拉流会话
+	Name      string `json:"name"`       // 数据通道名, 如chat/reaction/whiteboard
 	Uid       int64  `json:"uid"`
 }
+
+type SubscribeDataChannelReq struct {
+	RoomId          string `json:"room_id"`
+	SessionId       string `json:"session_id"`        // 自己的推流/拉流会话
+	RemoteSessionId string `json:"remote_session_id"` // 发布者的会话
+	Name            string `json:"name"`
+	Uid             int64  `json:"uid"`
+}
+
+type DataChannelResp struct {
+	Name string `json:"name"`
+	Id   int    `json:"id"`
+}
+
+type CloseDataChannelReq struct {
+	RoomId    string   `json:"room_id"`
+	SessionId string   `json:"session_id"`
+	Names     []string `json:"names"`
+	Uid       int64    `json:"uid"`
+}
@@ -3,8 +3,16 @@ package errorx
 import "github.com/thk-im/thk-im-base-server/errorx"
 
 var (
-	ErrRoomNotExisted   = errorx.NewErrorX(4004001, "RoomNotExisted")
-	ErrNoPermission     = errorx.NewErrorX(4004002, "NoPermission")
-	ErrPusherNotExisted = errorx.NewErrorX(4004003, "PusherNotExisted")
-	ErrRoomModeConflict = errorx.NewErrorX(4004004, "RoomModeConflict")
+	ErrRoomNotExisted     = errorx.NewErrorX(4004001, "RoomNotExisted")
+	ErrNoPermission       = errorx.NewErrorX(4004002, "NoPermission")
+	ErrPusherNotExisted   = errorx.NewErrorX(4004003, "PusherNotExisted")
+	ErrRoomModeConflict   = errorx.NewErrorX(4004004, "RoomModeConflict")
+	ErrEngineNotSupported = errorx.NewErrorX(4004005, "EngineNotSupported")
+	ErrNotBroadcaster     = errorx.NewErrorX(4004006, "NotBroadcaster")
+	ErrNotRoomMember      = errorx.NewErrorX(4004007, "NotRoomMember")
+	ErrMemberBanned       = errorx.NewErrorX(4004008, "MemberBanned")
+	ErrAdapterNotExisted  = errorx.NewErrorX(4004009, "AdapterNotExisted")
+	ErrScreenShareExisted = errorx.NewErrorX(4004010, "ScreenShareExisted")
+	ErrRoomEnded          = errorx.NewErrorX(4004011, "RoomEnded")
+	ErrRoomStatusInvalid  = errorx.NewErrorX(4004012, "RoomStatusInvalid")
 )
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	baseMiddleware "github.com/thk-im/thk-im-base-server/middleware"
+	"github.com/thk-im/thk-im-livecall-server/pkg/app"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/logic"
+	msgSdk "github.com/thk-im/thk-im-msgapi-server/pkg/sdk"
+)
+
+// forwardTrack 转发成员track到WebSocket, 只对后端服务开放
+func forwardTrack(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewAdapterLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.ForwardTrackReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("forwardTrack %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid > 0 {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("forwardTrack %d %v", requestUid, req)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+
+		if resp, err := l.ForwardTrack(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("forwardTrack %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("forwardTrack %v %v", req, resp)
+			baseDto.ResponseSuccess(ctx, resp)
+		}
+	}
+}
+
+// injectAudio 从WebSocket推入音频, 只对后端服务开放
+func injectAudio(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewAdapterLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.InjectAudioReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("injectAudio %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid > 0 {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("injectAudio %d %v", requestUid, req)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+
+		if resp, err := l.InjectAudio(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("injectAudio %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("injectAudio %v %v", req, resp)
+			baseDto.ResponseSuccess(ctx, resp)
+		}
+	}
+}
+
+// closeAdapter 关闭适配器, 只对后端服务开放
+func closeAdapter(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewAdapterLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.CloseAdapterReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("closeAdapter %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid > 0 {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("closeAdapter %d %v", requestUid, req)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+
+		if err := l.CloseAdapter(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("closeAdapter %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("closeAdapter %v", req)
+			baseDto.ResponseSuccess(ctx, nil)
+		}
+	}
+}
@@ -23,8 +23,28 @@ func RegisterRtcHandler(appCtx *app.Context) {
 	room.POST("/member/invite", inviteJoinRoom(appCtx))
 	room.POST("/member/refuse_join", refuseJoinRoom(appCtx))
 	room.POST("/member/kick", KickoffRoomMember(appCtx))
+	room.POST("/member/mute", muteRoomMember(appCtx))
+	room.POST("/member/promote", promoteRoomMember(appCtx))
+	room.POST("/member/demote", demoteRoomMember(appCtx))
+	room.POST("/member/hand/raise", raiseHand(appCtx))
+	room.POST("/member/hand/lower", lowerHand(appCtx))
+	room.POST("/member/speak/grant", grantSpeak(appCtx))
+	room.POST("/member/speak/revoke", revokeSpeak(appCtx))
+	room.POST("/member/moderator/grant", grantModerator(appCtx))
+	room.POST("/member/moderator/revoke", revokeModerator(appCtx))
 	room.POST("/member/leave", leaveRoomMember(appCtx))
+	room.POST("/member/heartbeat", memberHeartbeat(appCtx))
 	room.DELETE("", deleteRoom(appCtx))
+	room.POST("/adapter/forward", forwardTrack(appCtx))
+	room.POST("/adapter/inject", injectAudio(appCtx))
+	room.POST("/adapter/close", closeAdapter(appCtx))
+
+	user := liveCallRoute.Group("/user")
+	user.GET("/:uid/status", queryUserStatus(appCtx))
+
+	history := liveCallRoute.Group("/history")
+	history.GET("/user", queryUserCallHistory(appCtx))
+	history.GET("/session", querySessionCallHistory(appCtx))
 
 	rtcEvent := liveCallRoute.Group("/rtc_event")
 	rtcEvent.Use(ipAuth)
@@ -36,5 +56,15 @@ func RegisterRtcHandler(appCtx *app.Context) {
 	streamRoute.Use(userTokenAuth)
 	streamRoute.POST("/publish", publishStream(appCtx))
 	streamRoute.POST("/subscribe", subscribeStream(appCtx))
+	streamRoute.POST("/unsubscribe", unsubscribeStream(appCtx))
 	streamRoute.PUT("/status", updateStreamStatus(appCtx))
+	streamRoute.PUT("/renegotiate", renegotiateStream(appCtx))
+	streamRoute.POST("/tracks/add", addStreamTracks(appCtx))
+	streamRoute.PUT("/tracks/update", updateStreamTracks(appCtx))
+	streamRoute.POST("/tracks/close", closeStreamTracks(appCtx))
+	streamRoute.PUT("/layer", setSimulcastLayer(appCtx))
+	streamRoute.POST("/datachannel/transport", establishDataChannelTransport(appCtx))
+	streamRoute.POST("/datachannel/publish", publishDataChannel(appCtx))
+	streamRoute.POST("/datachannel/subscribe", subscribeDataChannel(appCtx))
+	streamRoute.POST("/datachannel/close", closeDataChannel(appCtx))
 }
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	baseMiddleware "github.com/thk-im/thk-im-base-server/middleware"
+	"github.com/thk-im/thk-im-livecall-server/pkg/app"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/logic"
+	msgSdk "github.com/thk-im/thk-im-msgapi-server/pkg/sdk"
+)
+
+func queryUserCallHistory(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewHistoryLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.QueryUserCallHistoryReq{}
+		if err := ctx.BindQuery(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("queryUserCallHistory %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid > 0 && requestUid != req.UId {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("queryUserCallHistory %d %d", requestUid, req.UId)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+
+		if resp, err := l.QueryUserCallHistory(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("queryUserCallHistory %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("queryUserCallHistory %v", req)
+			baseDto.ResponseSuccess(ctx, resp)
+		}
+	}
+}
+
+// querySessionCallHistory 会话通话记录包含所有成员的通话, 用户只能查询自己参与过通话的会话
+func querySessionCallHistory(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewHistoryLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.QuerySessionCallHistoryReq{}
+		if err := ctx.BindQuery(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("querySessionCallHistory %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid > 0 {
+			isMember, errMember := l.IsSessionCallMember(requestUid, req.SessionId)
+			if errMember != nil {
+				appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("querySessionCallHistory %d %v %s", requestUid, req, errMember.Error())
+				baseDto.ResponseInternalServerError(ctx, errMember)
+				return
+			}
+			if !isMember {
+				appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("querySessionCallHistory %d %v", requestUid, req)
+				baseDto.ResponseForbidden(ctx)
+				return
+			}
+		}
+
+		if resp, err := l.QuerySessionCallHistory(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("querySessionCallHistory %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("querySessionCallHistory %v", req)
+			baseDto.ResponseSuccess(ctx, resp)
+		}
+	}
+}
@@ -55,12 +55,12 @@ func callRoomMembers(appCtx *app.Context) gin.HandlerFunc {
 			return
 		}
 
-		if err := l.CallRoomMembers(req, claims); err != nil {
+		if resp, err := l.CallRoomMembers(req, claims); err != nil {
 			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("callRoomMembers %v %s", req, err.Error())
 			baseDto.ResponseInternalServerError(ctx, err)
 		} else {
-			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("callRoomMembers %v", req)
-			baseDto.ResponseSuccess(ctx, nil)
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("callRoomMembers %v %v", req, resp)
+			baseDto.ResponseSuccess(ctx, resp)
 		}
 	}
 }
@@ -190,12 +190,12 @@ func inviteJoinRoom(appCtx *app.Context) gin.HandlerFunc {
 			return
 		}
 
-		if err := l.InviteJoinRoom(req, claims); err != nil {
+		if resp, err := l.InviteJoinRoom(req, claims); err != nil {
 			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("inviteJoinRoom %v %s", req, err.Error())
 			baseDto.ResponseInternalServerError(ctx, err)
 		} else {
-			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("inviteJoinRoom %v", req)
-			baseDto.ResponseSuccess(ctx, nil)
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("inviteJoinRoom %v %v", req, resp)
+			baseDto.ResponseSuccess(ctx, resp)
 		}
 	}
 }
@@ -228,7 +228,7 @@ func leaveRoomMember(appCtx *app.Context) gin.HandlerFunc {
 }
 
 func KickoffRoomMember(appCtx *app.Context) gin.HandlerFunc {
-	l := logic.NewRoomLogic(appCtx)
+	l := logic.NewStreamLogic(appCtx)
 	return func(ctx *gin.Context) {
 		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
 		req := &dto.KickoffMemberReq{}
@@ -254,6 +254,60 @@ func KickoffRoomMember(appCtx *app.Context) gin.HandlerFunc {
 	}
 }
 
+func promoteRoomMember(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewRoomLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.MemberRoleReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("promoteRoomMember %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid > 0 && requestUid != req.UId {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("promoteRoomMember %v", req)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+
+		if err := l.PromoteMember(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("promoteRoomMember %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("promoteRoomMember %v", req)
+			baseDto.ResponseSuccess(ctx, nil)
+		}
+	}
+}
+
+func demoteRoomMember(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewRoomLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.MemberRoleReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("demoteRoomMember %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid > 0 && requestUid != req.UId {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("demoteRoomMember %v", req)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+
+		if err := l.DemoteMember(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("demoteRoomMember %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("demoteRoomMember %v", req)
+			baseDto.ResponseSuccess(ctx, nil)
+		}
+	}
+}
+
 func findRoomById(appCtx *app.Context) gin.HandlerFunc {
 	l := logic.NewRoomLogic(appCtx)
 	return func(ctx *gin.Context) {
@@ -272,3 +326,219 @@ func findRoomById(appCtx *app.Context) gin.HandlerFunc {
 		}
 	}
 }
+
+func raiseHand(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewRoomLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.HandReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("raiseHand %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid > 0 && requestUid != req.UId {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("raiseHand %v", req)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+
+		if err := l.RaiseHand(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("raiseHand %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("raiseHand %v", req)
+			baseDto.ResponseSuccess(ctx, nil)
+		}
+	}
+}
+
+func lowerHand(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewRoomLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.HandReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("lowerHand %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid > 0 && requestUid != req.UId {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("lowerHand %v", req)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+
+		if err := l.LowerHand(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("lowerHand %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("lowerHand %v", req)
+			baseDto.ResponseSuccess(ctx, nil)
+		}
+	}
+}
+
+func grantSpeak(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewRoomLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.MemberRoleReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("grantSpeak %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid > 0 && requestUid != req.UId {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("grantSpeak %v", req)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+
+		if err := l.GrantSpeak(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("grantSpeak %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("grantSpeak %v", req)
+			baseDto.ResponseSuccess(ctx, nil)
+		}
+	}
+}
+
+func revokeSpeak(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewRoomLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.MemberRoleReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("revokeSpeak %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid > 0 && requestUid != req.UId {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("revokeSpeak %v", req)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+
+		if err := l.RevokeSpeak(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("revokeSpeak %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("revokeSpeak %v", req)
+			baseDto.ResponseSuccess(ctx, nil)
+		}
+	}
+}
+
+func grantModerator(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewRoomLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.MemberRoleReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("grantModerator %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid > 0 && requestUid != req.UId {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("grantModerator %v", req)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+
+		if err := l.GrantModerator(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("grantModerator %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("grantModerator %v", req)
+			baseDto.ResponseSuccess(ctx, nil)
+		}
+	}
+}
+
+func revokeModerator(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewRoomLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.MemberRoleReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("revokeModerator %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid > 0 && requestUid != req.UId {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("revokeModerator %v", req)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+
+		if err := l.RevokeModerator(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("revokeModerator %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("revokeModerator %v", req)
+			baseDto.ResponseSuccess(ctx, nil)
+		}
+	}
+}
+
+func muteRoomMember(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewStreamLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.MuteMemberReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("muteRoomMember %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid > 0 && requestUid != req.UId {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("muteRoomMember %v", req)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+
+		if err := l.MuteMember(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("muteRoomMember %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("muteRoomMember %v", req)
+			baseDto.ResponseSuccess(ctx, nil)
+		}
+	}
+}
+
+func memberHeartbeat(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewRoomLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.MemberHeartbeatReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("memberHeartbeat %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid > 0 && requestUid != req.UId {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("memberHeartbeat %v", req)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+
+		if err := l.MemberHeartbeat(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("memberHeartbeat %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("memberHeartbeat %v", req)
+			baseDto.ResponseSuccess(ctx, nil)
+		}
+	}
+}
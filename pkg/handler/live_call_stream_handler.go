@@ -12,7 +12,7 @@ import (
 )
 
 func publishStream(appCtx *app.Context) gin.HandlerFunc {
-	l := logic.NewWebRTCStreamLogic(appCtx)
+	l := logic.NewStreamLogic(appCtx)
 	return func(ctx *gin.Context) {
 		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
 		req := &dto.PublishStreamReq{}
@@ -40,7 +40,7 @@ func publishStream(appCtx *app.Context) gin.HandlerFunc {
 }
 
 func subscribeStream(appCtx *app.Context) gin.HandlerFunc {
-	l := logic.NewWebRTCStreamLogic(appCtx)
+	l := logic.NewStreamLogic(appCtx)
 	return func(ctx *gin.Context) {
 		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
 		req := &dto.SubscribeStreamReq{}
@@ -68,7 +68,7 @@ func subscribeStream(appCtx *app.Context) gin.HandlerFunc {
 }
 
 func updateStreamStatus(appCtx *app.Context) gin.HandlerFunc {
-	l := logic.NewWebRTCStreamLogic(appCtx)
+	l := logic.NewStreamLogic(appCtx)
 	return func(ctx *gin.Context) {
 		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
 		req := &dto.StreamStatusUpdateReq{}
@@ -94,3 +94,283 @@ func updateStreamStatus(appCtx *app.Context) gin.HandlerFunc {
 		}
 	}
 }
+
+func establishDataChannelTransport(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewStreamLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.DataChannelTransportReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("establishDataChannelTransport %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid <= 0 {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("establishDataChannelTransport %d", requestUid)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+		req.Uid = requestUid
+
+		if resp, err := l.EstablishDataChannelTransport(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("establishDataChannelTransport %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("establishDataChannelTransport %v %v", req, resp)
+			baseDto.ResponseSuccess(ctx, resp)
+		}
+	}
+}
+
+func publishDataChannel(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewStreamLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.PublishDataChannelReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("publishDataChannel %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid <= 0 {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("publishDataChannel %d", requestUid)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+		req.Uid = requestUid
+
+		if resp, err := l.PublishDataChannel(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("publishDataChannel %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("publishDataChannel %v %v", req, resp)
+			baseDto.ResponseSuccess(ctx, resp)
+		}
+	}
+}
+
+func subscribeDataChannel(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewStreamLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.SubscribeDataChannelReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("subscribeDataChannel %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid <= 0 {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("subscribeDataChannel %d", requestUid)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+		req.Uid = requestUid
+
+		if resp, err := l.SubscribeDataChannel(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("subscribeDataChannel %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("subscribeDataChannel %v %v", req, resp)
+			baseDto.ResponseSuccess(ctx, resp)
+		}
+	}
+}
+
+func closeDataChannel(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewStreamLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.CloseDataChannelReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("closeDataChannel %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid <= 0 {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("closeDataChannel %d", requestUid)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+		req.Uid = requestUid
+
+		if err := l.CloseDataChannel(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("closeDataChannel %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("closeDataChannel %v", req)
+			baseDto.ResponseSuccess(ctx, nil)
+		}
+	}
+}
+
+func renegotiateStream(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewStreamLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.RenegotiateReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("renegotiateStream %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid <= 0 {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("renegotiateStream %d", requestUid)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+		req.Uid = requestUid
+
+		if resp, err := l.Renegotiate(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("renegotiateStream %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("renegotiateStream %v %v", req, resp)
+			baseDto.ResponseSuccess(ctx, resp)
+		}
+	}
+}
+
+func addStreamTracks(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewStreamLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.StreamTracksReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("addStreamTracks %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid <= 0 {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("addStreamTracks %d", requestUid)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+		req.Uid = requestUid
+
+		if resp, err := l.AddTracks(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("addStreamTracks %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("addStreamTracks %v %v", req, resp)
+			baseDto.ResponseSuccess(ctx, resp)
+		}
+	}
+}
+
+func updateStreamTracks(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewStreamLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.StreamTracksReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("updateStreamTracks %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid <= 0 {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("updateStreamTracks %d", requestUid)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+		req.Uid = requestUid
+
+		if resp, err := l.UpdateTracks(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("updateStreamTracks %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("updateStreamTracks %v %v", req, resp)
+			baseDto.ResponseSuccess(ctx, resp)
+		}
+	}
+}
+
+func unsubscribeStream(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewStreamLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.UnsubscribeStreamReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("unsubscribeStream %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid <= 0 {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("unsubscribeStream %d", requestUid)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+		req.Uid = requestUid
+
+		if resp, err := l.UnsubscribeStream(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("unsubscribeStream %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("unsubscribeStream %v %v", req, resp)
+			baseDto.ResponseSuccess(ctx, resp)
+		}
+	}
+}
+
+func closeStreamTracks(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewStreamLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.CloseStreamTracksReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("closeStreamTracks %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid <= 0 {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("closeStreamTracks %d", requestUid)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+		req.Uid = requestUid
+
+		if err := l.CloseStreamTracks(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("closeStreamTracks %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("closeStreamTracks %v", req)
+			baseDto.ResponseSuccess(ctx, nil)
+		}
+	}
+}
+
+func setSimulcastLayer(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewStreamLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		req := &dto.SimulcastLayerReq{}
+		if err := ctx.BindJSON(req); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("setSimulcastLayer %s", err.Error())
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if requestUid <= 0 {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("setSimulcastLayer %d", requestUid)
+			baseDto.ResponseForbidden(ctx)
+			return
+		}
+		req.Uid = requestUid
+
+		if resp, err := l.SetSimulcastLayer(req, claims); err != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("setSimulcastLayer %v %s", req, err.Error())
+			baseDto.ResponseInternalServerError(ctx, err)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("setSimulcastLayer %v %v", req, resp)
+			baseDto.ResponseSuccess(ctx, resp)
+		}
+	}
+}
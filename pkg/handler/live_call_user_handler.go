@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	baseMiddleware "github.com/thk-im/thk-im-base-server/middleware"
+	"github.com/thk-im/thk-im-livecall-server/pkg/app"
+	"github.com/thk-im/thk-im-livecall-server/pkg/logic"
+	msgSdk "github.com/thk-im/thk-im-msgapi-server/pkg/sdk"
+)
+
+// queryUserStatus 查询用户是否正在通话中, 发起呼叫前可用于判断忙线, 非本人查询时不返回所在房间
+func queryUserStatus(appCtx *app.Context) gin.HandlerFunc {
+	l := logic.NewRoomLogic(appCtx)
+	return func(ctx *gin.Context) {
+		claims := ctx.MustGet(baseMiddleware.ClaimsKey).(baseDto.ThkClaims)
+		uId, err := strconv.ParseInt(ctx.Param("uid"), 10, 64)
+		if err != nil || uId <= 0 {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("queryUserStatus %s", ctx.Param("uid"))
+			baseDto.ResponseBadRequest(ctx)
+			return
+		}
+
+		requestUid := ctx.GetInt64(msgSdk.UidKey)
+		if resp, errStatus := l.QueryUserStatus(uId, requestUid, claims); errStatus != nil {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Errorf("queryUserStatus %d %s", uId, errStatus.Error())
+			baseDto.ResponseInternalServerError(ctx, errStatus)
+		} else {
+			appCtx.Logger().WithFields(logrus.Fields(claims)).Tracef("queryUserStatus %d %v", uId, resp)
+			baseDto.ResponseSuccess(ctx, resp)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/thk-im/thk-im-base-server/conf"
 	"github.com/thk-im/thk-im-base-server/snowflake"
+	"github.com/thk-im/thk-im-livecall-server/pkg/model"
 	"gorm.io/gorm"
 )
 
@@ -18,6 +19,11 @@ func LoadModels(modeConfigs []conf.Model, database *gorm.DB, logger *logrus.Entr
 	}
 	for _, ms := range modeConfigs {
 		var m interface{}
+		if ms.Name == "live_call_record" {
+			m = model.NewCallRecordModel(database, logger, snowflakeNode, ms.Shards)
+		} else if ms.Name == "live_call_leg" {
+			m = model.NewCallLegModel(database, logger, snowflakeNode, ms.Shards)
+		}
 		modelMap[ms.Name] = m
 	}
 	return modelMap
@@ -25,6 +31,9 @@ func LoadModels(modeConfigs []conf.Model, database *gorm.DB, logger *logrus.Entr
 
 // LoadTables 与 sirius-server 一致：从 ./sql/{Name}.sql 建表，内容中表名需含 %s 作为分表后缀；Shards=1 时后缀为 ""。
 func LoadTables(modeConfigs []conf.Model, database *gorm.DB) error {
+	if database == nil {
+		return nil
+	}
 	for _, ms := range modeConfigs {
 		if ms.Name == "user_device" {
 			continue
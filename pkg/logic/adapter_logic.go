@@ -0,0 +1,228 @@
+package logic
+
+import (
+	"time"
+
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	baseErr "github.com/thk-im/thk-im-base-server/errorx"
+	"github.com/thk-im/thk-im-livecall-server/pkg/app"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/errorx"
+	roomSvc "github.com/thk-im/thk-im-livecall-server/pkg/service/room"
+	"github.com/thk-im/thk-im-livecall-server/pkg/service/signal"
+)
+
+// AdapterLogic Cloudflare Calls WebSocket适配器, 将通话音频接入转写/AI助手等后端服务
+type AdapterLogic struct {
+	appCtx        *app.Context
+	roomLogic     *RoomLogic
+	signalService signal.Service
+}
+
+func NewAdapterLogic(appCtx *app.Context) *AdapterLogic {
+	return &AdapterLogic{
+		appCtx:        appCtx,
+		roomLogic:     NewRoomLogic(appCtx),
+		signalService: signal.NewSignalService(appCtx),
+	}
+}
+
+// ForwardTrack 将成员的track转发到配置的WebSocket地址
+func (l AdapterLogic) ForwardTrack(req *dto.ForwardTrackReq, claims baseDto.ThkClaims) (*dto.RoomAdapter, error) {
+	room, err := l.checkRoom(req.RoomId, claims)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := l.endpoint(req.Name)
+	if endpoint == "" {
+		return nil, errorx.ErrAdapterNotExisted
+	}
+	trackName := req.TrackName
+	if trackName == "" {
+		trackName = dto.TrackMic
+	}
+	outputCodec := req.OutputCodec
+	if outputCodec == "" {
+		outputCodec = "pcm"
+	}
+
+	var track *dto.ParticipantTrack
+	for _, p := range room.Participants {
+		if p.UId != req.MemberUId {
+			continue
+		}
+		for _, t := range p.Tracks {
+			if t.TrackName == trackName {
+				track = t
+				break
+			}
+		}
+	}
+	if track == nil {
+		return nil, errorx.ErrPusherNotExisted
+	}
+
+	adapterResp, errAdapter := l.appCtx.CloudflareConnectApi().NewAdapter(&dto.NewAdapterRequest{
+		Tracks: []dto.AdapterObject{{
+			Location:    "remote",
+			SessionID:   track.SessionId,
+			TrackName:   track.TrackName,
+			Endpoint:    endpoint,
+			OutputCodec: outputCodec,
+		}},
+	})
+	adapterTrack, errTrack := l.adapterTrack(adapterResp, errAdapter)
+	if errTrack != nil {
+		return nil, errTrack
+	}
+
+	adapter := &dto.RoomAdapter{
+		AdapterId:  adapterTrack.AdapterID,
+		Direction:  dto.AdapterForward,
+		Name:       req.Name,
+		UId:        req.MemberUId,
+		SessionId:  track.SessionId,
+		TrackName:  track.TrackName,
+		CreateTime: time.Now().UnixMilli(),
+	}
+	if errSave := l.roomLogic.SaveRoomAdapter(room.Id, adapter); errSave != nil {
+		return nil, errSave
+	}
+	return adapter, nil
+}
+
+// InjectAudio 从配置的WebSocket地址接收pcm音频推入房间, 其他成员按推流会话订阅
+func (l AdapterLogic) InjectAudio(req *dto.InjectAudioReq, claims baseDto.ThkClaims) (*dto.RoomAdapter, error) {
+	room, err := l.checkRoom(req.RoomId, claims)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := l.endpoint(req.Name)
+	if endpoint == "" {
+		return nil, errorx.ErrAdapterNotExisted
+	}
+	trackName := req.TrackName
+	if trackName == "" {
+		trackName = dto.TrackMic
+	}
+
+	adapterResp, errAdapter := l.appCtx.CloudflareConnectApi().NewAdapter(&dto.NewAdapterRequest{
+		Tracks: []dto.AdapterObject{{
+			Location:   "local",
+			TrackName:  trackName,
+			Endpoint:   endpoint,
+			InputCodec: "pcm",
+			Mode:       "buffer",
+		}},
+	})
+	adapterTrack, errTrack := l.adapterTrack(adapterResp, errAdapter)
+	if errTrack != nil {
+		return nil, errTrack
+	}
+
+	now := time.Now().UnixMilli()
+	adapter := &dto.RoomAdapter{
+		AdapterId:  adapterTrack.AdapterID,
+		Direction:  dto.AdapterInject,
+		Name:       req.Name,
+		UId:        req.UId,
+		SessionId:  adapterTrack.SessionID,
+		TrackName:  adapterTrack.TrackName,
+		CreateTime: now,
+	}
+	if errSave := l.roomLogic.SaveRoomAdapter(room.Id, adapter); errSave != nil {
+		return nil, errSave
+	}
+
+	s := dto.MakeParticipantPushStreamSignal(room.Id, adapter.SessionId, req.UId, now)
+	if errPush := l.signalService.PushSignal(s, l.roomLogic.memberIds(room), claims); errPush != nil {
+		l.appCtx.Logger().Error("InjectAudio PushSignal err, ", errPush)
+	}
+	return adapter, nil
+}
+
+// CloseAdapter 关闭房间内的适配器
+func (l AdapterLogic) CloseAdapter(req *dto.CloseAdapterReq, claims baseDto.ThkClaims) error {
+	room, err := l.checkRoom(req.RoomId, claims)
+	if err != nil {
+		return err
+	}
+	adapters, errAdapters := l.roomLogic.FindRoomAdapters(room.Id)
+	if errAdapters != nil {
+		return errAdapters
+	}
+	adapterMap := make(map[string]*dto.RoomAdapter)
+	for _, adapter := range adapters {
+		adapterMap[adapter.AdapterId] = adapter
+	}
+	for _, adapterId := range req.AdapterIds {
+		if adapterMap[adapterId] == nil {
+			return errorx.ErrAdapterNotExisted
+		}
+	}
+	if len(req.AdapterIds) == 0 {
+		return nil
+	}
+	closer, ok := l.roomLogic.Engine(room.Engine).(roomSvc.AdapterCloser)
+	if !ok {
+		return errorx.ErrEngineNotSupported
+	}
+	if errClose := closer.CloseAdapters(req.AdapterIds); errClose != nil {
+		return errClose
+	}
+	if errDel := l.roomLogic.DeleteRoomAdapters(room.Id, req.AdapterIds); errDel != nil {
+		return errDel
+	}
+
+	now := time.Now().UnixMilli()
+	for _, adapterId := range req.AdapterIds {
+		adapter := adapterMap[adapterId]
+		if adapter.Direction != dto.AdapterInject {
+			continue
+		}
+		s := dto.MakeParticipantLeaveSignal(room.Id, adapter.SessionId, adapter.UId, now)
+		if errPush := l.signalService.PushSignal(s, l.roomLogic.memberIds(room), claims); errPush != nil {
+			l.appCtx.Logger().Error("CloseAdapter PushSignal err, ", errPush)
+		}
+	}
+	return nil
+}
+
+// checkRoom 适配器只支持Cloudflare Calls引擎的房间
+func (l AdapterLogic) checkRoom(roomId string, claims baseDto.ThkClaims) (*dto.Room, error) {
+	room, err := l.roomLogic.QueryRoom(roomId, claims)
+	if err != nil {
+		return nil, err
+	}
+	if room == nil {
+		return nil, errorx.ErrRoomNotExisted
+	}
+	if room.IsEnded() {
+		return nil, errorx.ErrRoomEnded
+	}
+	if room.Engine != dto.EngineCloudflareSFU || l.appCtx.CloudflareConnectApi() == nil {
+		return nil, errorx.ErrEngineNotSupported
+	}
+	return room, nil
+}
+
+func (l AdapterLogic) adapterTrack(resp *dto.NewAdapterResponse, err error) (*dto.AdapterResponseTrack, error) {
+	if err != nil {
+		l.appCtx.Logger().Error("NewAdapter err, ", err)
+		return nil, baseErr.ErrInternalServerError
+	}
+	if resp == nil || resp.ErrorCode != "" || len(resp.Tracks) == 0 || resp.Tracks[0].ErrorCode != "" {
+		l.appCtx.Logger().Error("NewAdapter resp err", resp)
+		return nil, baseErr.ErrInternalServerError
+	}
+	return &resp.Tracks[0], nil
+}
+
+func (l AdapterLogic) endpoint(name string) string {
+	for _, adapter := range l.appCtx.LiveCallConfig().Adapters {
+		if adapter.Name == name {
+			return adapter.Endpoint
+		}
+	}
+	return ""
+}
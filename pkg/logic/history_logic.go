@@ -0,0 +1,106 @@
+package logic
+
+import (
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/app"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/model"
+)
+
+type HistoryLogic struct {
+	appCtx *app.Context
+}
+
+func NewHistoryLogic(appCtx *app.Context) *HistoryLogic {
+	return &HistoryLogic{appCtx: appCtx}
+}
+
+func (l HistoryLogic) QueryUserCallHistory(req *dto.QueryUserCallHistoryReq, claims baseDto.ThkClaims) (*dto.QueryUserCallHistoryResp, error) {
+	resp := &dto.QueryUserCallHistoryResp{Data: make([]*dto.CallLegVo, 0)}
+	if l.appCtx.CallLegModel() == nil {
+		return resp, nil
+	}
+	legs, err := l.appCtx.CallLegModel().FindCallLegsByUser(req.UId, l.offset(req.Offset), l.count(req.Count))
+	if err != nil {
+		return nil, err
+	}
+	for _, leg := range legs {
+		resp.Data = append(resp.Data, l.callLegVo(leg))
+	}
+	return resp, nil
+}
+
+func (l HistoryLogic) QuerySessionCallHistory(req *dto.QuerySessionCallHistoryReq, claims baseDto.ThkClaims) (*dto.QuerySessionCallHistoryResp, error) {
+	resp := &dto.QuerySessionCallHistoryResp{Data: make([]*dto.CallRecordVo, 0)}
+	if l.appCtx.CallRecordModel() == nil {
+		return resp, nil
+	}
+	records, err := l.appCtx.CallRecordModel().FindCallRecordsBySession(req.SessionId, l.offset(req.Offset), l.count(req.Count))
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		resp.Data = append(resp.Data, l.callRecordVo(record))
+	}
+	return resp, nil
+}
+
+// IsSessionCallMember 用户是否为会话通话的成员, 以用户通话记录中的会话id判断
+func (l HistoryLogic) IsSessionCallMember(uId, sessionId int64) (bool, error) {
+	if l.appCtx.CallLegModel() == nil {
+		return false, nil
+	}
+	return l.appCtx.CallLegModel().ExistsSessionCallLeg(uId, sessionId)
+}
+
+func (l HistoryLogic) callLegVo(leg *model.CallLeg) *dto.CallLegVo {
+	return &dto.CallLegVo{
+		RoomId:     leg.RoomId,
+		UId:        leg.UId,
+		SessionId:  leg.SessionId,
+		OwnerId:    leg.OwnerId,
+		Mode:       leg.Mode,
+		Engine:     leg.Engine,
+		Role:       leg.Role,
+		JoinTime:   leg.JoinTime,
+		LeaveTime:  leg.LeaveTime,
+		RefuseTime: leg.RefuseTime,
+		KickTime:   leg.KickTime,
+		Duration:   leg.Duration,
+		CreateTime: leg.CreateTime,
+		EndTime:    leg.EndTime,
+	}
+}
+
+func (l HistoryLogic) callRecordVo(record *model.CallRecord) *dto.CallRecordVo {
+	return &dto.CallRecordVo{
+		RoomId:      record.RoomId,
+		SessionId:   record.SessionId,
+		OwnerId:     record.OwnerId,
+		Mode:        record.Mode,
+		Engine:      record.Engine,
+		Accepted:    record.Accepted,
+		AcceptTime:  record.AcceptTime,
+		Duration:    record.Duration,
+		MemberCount: record.MemberCount,
+		CreateTime:  record.CreateTime,
+		EndTime:     record.EndTime,
+	}
+}
+
+func (l HistoryLogic) offset(offset int) int {
+	if offset < 0 {
+		return 0
+	}
+	return offset
+}
+
+func (l HistoryLogic) count(count int) int {
+	if count <= 0 {
+		return dto.DefaultHistoryCount
+	}
+	if count > dto.MaxHistoryCount {
+		return dto.MaxHistoryCount
+	}
+	return count
+}
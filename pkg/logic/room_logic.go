@@ -22,11 +22,16 @@ type RoomLogic struct {
 func NewRoomLogic(appCtx *app.Context) *RoomLogic {
 	return &RoomLogic{
 		appCtx:        appCtx,
-		roomService:   room.NewCloudflareSFURoomService(appCtx),
+		roomService:   room.NewRoomService(appCtx),
 		signalService: signal.NewSignalService(appCtx),
 	}
 }
 
+// StartScheduler 启动房间后台任务
+func (l RoomLogic) StartScheduler() {
+	room.NewScheduler(l.appCtx, l.roomService).Start()
+}
+
 func (l RoomLogic) CreateRoom(req *dto.RoomCreateReq, claims baseDto.ThkClaims) (*dto.RoomJoinResp, error) {
 	return l.roomService.CreateRoom(req, claims)
 }
@@ -43,6 +48,32 @@ func (l RoomLogic) JoinRoom(req *dto.RoomJoinReq, claims baseDto.ThkClaims) (*dt
 	if roomVo == nil {
 		return nil, errorx.ErrRoomNotExisted
 	}
+	if roomVo.IsEnded() {
+		return nil, errorx.ErrRoomEnded
+	}
+	banned, errBan := l.roomService.IsMemberBanned(roomVo.Id, req.UId, claims)
+	if errBan != nil {
+		return nil, errBan
+	}
+	if banned {
+		return nil, errorx.ErrMemberBanned
+	}
+
+	// 语音房/视频房允许未被邀请的用户以观众身份加入
+	if dto.IsLiveRoomMode(roomVo.Mode) {
+		isMember := false
+		for _, p := range roomVo.Participants {
+			if p.UId == req.UId && !p.IsKicked() {
+				isMember = true
+				break
+			}
+		}
+		if !isMember {
+			if errAdd := l.roomService.AddRoomMember(roomVo.Id, req.UId, 0, dto.Audience, claims); errAdd != nil {
+				return nil, errAdd
+			}
+		}
+	}
 
 	resp, errRequestJoin := l.roomService.RequestJoinRoom(req, claims)
 	if errRequestJoin != nil {
@@ -66,35 +97,25 @@ func (l RoomLogic) JoinRoom(req *dto.RoomJoinReq, claims baseDto.ThkClaims) (*dt
 	return resp, nil
 }
 
-func (l RoomLogic) CallRoomMembers(req *dto.RoomCallReq, claims baseDto.ThkClaims) error {
+func (l RoomLogic) CallRoomMembers(req *dto.RoomCallReq, claims baseDto.ThkClaims) (*dto.CallMembersResp, error) {
 	roomVo, err := l.roomService.FindRoomById(req.RoomId, claims)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if roomVo == nil {
-		return errorx.ErrRoomNotExisted
+		return nil, errorx.ErrRoomNotExisted
 	}
-
-	if len(req.Members) == 0 {
-		return baseErrorx.ErrParamsError
+	if roomVo.IsEnded() {
+		return nil, errorx.ErrRoomEnded
 	}
 
-	memberMap := make(map[int64]*dto.Participant)
-	for _, member := range roomVo.Participants {
-		memberMap[member.UId] = member
+	if !roomVo.HasCapability(req.UId, dto.CapInvite) {
+		return nil, errorx.ErrNoPermission
 	}
-	for _, member := range req.Members {
-		if memberMap[member] == nil {
-			_ = l.roomService.AddRoomMember(roomVo.Id, member, claims)
-		}
+	if len(req.Members) == 0 {
+		return nil, baseErrorx.ErrParamsError
 	}
-
-	s := dto.MakeBeingRequestedSignal(
-		roomVo.Id, req.Members, roomVo.Mode, req.Msg, req.UId, roomVo.CreateTime,
-		time.Now().UnixMilli()+req.Duration*1000,
-	)
-	errPush := l.signalService.PushSignal(s, req.Members, claims)
-	return errPush
+	return l.requestMembers(roomVo, req.UId, req.Members, req.Msg, req.Duration, req.CallWaiting, claims)
 }
 
 func (l RoomLogic) CancelCallRoomMembers(req *dto.CancelCallingReq, claims baseDto.ThkClaims) error {
@@ -105,6 +126,9 @@ func (l RoomLogic) CancelCallRoomMembers(req *dto.CancelCallingReq, claims baseD
 	if roomVo == nil {
 		return errorx.ErrRoomNotExisted
 	}
+	if !roomVo.HasCapability(req.UId, dto.CapInvite) {
+		return errorx.ErrNoPermission
+	}
 
 	if len(req.Members) > 0 {
 		s := dto.MakeCancelRequestingSignal(
@@ -114,53 +138,114 @@ func (l RoomLogic) CancelCallRoomMembers(req *dto.CancelCallingReq, claims baseD
 		if errPush != nil {
 			return errPush
 		}
+		l.updateCallState(roomVo.Id, req.Members, dto.CallStateCanceled, time.Now().UnixMilli(), dto.EndReasonCanceled, claims)
 	}
 
-	shouldEnd := false
-	joinedMembers := make([]int64, 0)
-	for _, p := range roomVo.Participants {
-		if p.JoinTime > 0 {
-			joinedMembers = append(joinedMembers, p.UId)
-		}
-	}
-	if len(joinedMembers) > 1 {
-		shouldEnd = false
-	} else {
-		shouldEnd = true
-	}
-
-	if shouldEnd {
-		return l.roomService.DestroyRoom(roomVo.Id, claims)
+	// 还没有人接听时取消呼叫即结束房间
+	if roomVo.Status == dto.RoomStatusRinging {
+		return l.roomService.DestroyRoom(roomVo.Id, req.UId, dto.EndReasonCanceled, claims)
 	}
-
 	return nil
 }
 
-func (l RoomLogic) InviteJoinRoom(req *dto.InviteJoinRoomReq, claims baseDto.ThkClaims) error {
+func (l RoomLogic) InviteJoinRoom(req *dto.InviteJoinRoomReq, claims baseDto.ThkClaims) (*dto.CallMembersResp, error) {
 	roomVo, err := l.roomService.FindRoomById(req.RoomId, claims)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if roomVo == nil {
-		return errorx.ErrRoomNotExisted
+		return nil, errorx.ErrRoomNotExisted
+	}
+	if roomVo.IsEnded() {
+		return nil, errorx.ErrRoomEnded
+	}
+	if !roomVo.HasCapability(req.UId, dto.CapInvite) {
+		return nil, errorx.ErrNoPermission
+	}
+	return l.requestMembers(roomVo, req.UId, req.InviteUIds, req.Msg, req.Duration, req.CallWaiting, claims)
+}
+
+// requestMembers 向成员发起呼叫, 正在其他通话中的成员按callWaiting推送呼叫等待或直接按忙线拒绝
+func (l RoomLogic) requestMembers(roomVo *dto.Room, uId int64, members []int64, msg string, duration int64, callWaiting bool, claims baseDto.ThkClaims) (*dto.CallMembersResp, error) {
+	resp := &dto.CallMembersResp{Results: make([]*dto.CallMemberResult, 0, len(members))}
+	ringMembers := make([]int64, 0, len(members))
+	waitingMembers := make([]int64, 0)
+	for _, member := range members {
+		// 新成员或之前未接听的成员重新进入已邀请状态, 已在通话中的成员不受影响
+		if errAdd := l.roomService.AddRoomMember(roomVo.Id, member, uId, dto.DefaultRole(roomVo.Mode), claims); errAdd != nil {
+			l.appCtx.Logger().Error("requestMembers AddRoomMember err, ", member, errAdd)
+		}
+		result := &dto.CallMemberResult{UId: member}
+		resp.Results = append(resp.Results, result)
+		busyRoomId, errBusy := l.roomService.FindUserRoom(member, claims)
+		if errBusy != nil {
+			l.appCtx.Logger().Error("requestMembers FindUserRoom err, ", member, errBusy)
+		}
+		if busyRoomId == "" || busyRoomId == roomVo.Id {
+			ringMembers = append(ringMembers, member)
+			continue
+		}
+		result.Busy = true
+		result.BusyRoomId = busyRoomId
+		if callWaiting {
+			result.Waiting = true
+			waitingMembers = append(waitingMembers, member)
+		} else if errRefuse := l.roomService.RefuseJoinRoom(roomVo.Id, member, true, claims); errRefuse != nil {
+			l.appCtx.Logger().Error("requestMembers RefuseJoinRoom err, ", member, errRefuse)
+		}
 	}
 
-	memberMap := make(map[int64]*dto.Participant)
-	for _, member := range roomVo.Participants {
-		memberMap[member.UId] = member
+	timeoutTime := time.Now().UnixMilli() + duration*1000
+	if len(ringMembers) > 0 {
+		s := dto.MakeBeingRequestedSignal(
+			roomVo.Id, members, roomVo.Mode, msg, uId, roomVo.CreateTime, timeoutTime,
+		)
+		if errPush := l.signalService.PushSignal(s, ringMembers, claims); errPush != nil {
+			return nil, errPush
+		}
+	}
+	now := time.Now().UnixMilli()
+	if len(waitingMembers) > 0 {
+		s := dto.MakeCallWaitingSignal(
+			roomVo.Id, members, roomVo.Mode, msg, uId, roomVo.CreateTime, timeoutTime,
+		)
+		if errPush := l.signalService.PushSignal(s, waitingMembers, claims); errPush != nil {
+			return nil, errPush
+		}
 	}
-	for _, member := range req.InviteUIds {
-		if memberMap[member] == nil {
-			_ = l.roomService.AddRoomMember(roomVo.Id, member, claims)
+	ringMembers = append(ringMembers, waitingMembers...)
+	l.updateCallState(roomVo.Id, ringMembers, dto.CallStateRinging, now, "", claims)
+	if duration > 0 && len(ringMembers) > 0 {
+		if errSchedule := l.roomService.ScheduleRingTimeout(roomVo.Id, uId, ringMembers, timeoutTime, claims); errSchedule != nil {
+			return nil, errSchedule
 		}
 	}
+	return resp, nil
+}
 
-	s := dto.MakeBeingRequestedSignal(
-		roomVo.Id, req.InviteUIds, roomVo.Mode, req.Msg, req.UId, roomVo.CreateTime,
-		time.Now().UnixMilli()+req.Duration*1000,
-	)
-	errPush := l.signalService.PushSignal(s, req.InviteUIds, claims)
-	return errPush
+// updateCallState 迁移成员的通话状态, 不允许的迁移忽略
+func (l RoomLogic) updateCallState(roomId string, members []int64, state int, stateTime int64, reason string, claims baseDto.ThkClaims) {
+	for _, member := range members {
+		err := l.roomService.UpdateMember(roomId, member, func(participant *dto.Participant) {
+			participant.TransitState(state, stateTime, reason)
+		}, claims)
+		if err != nil {
+			l.appCtx.Logger().Error("updateCallState err, ", roomId, member, state, err)
+		}
+	}
+}
+
+// QueryUserStatus 查询用户是否正在通话中, 只有用户本人或服务端(requestUid为0)可以查询所在房间
+func (l RoomLogic) QueryUserStatus(uId, requestUid int64, claims baseDto.ThkClaims) (*dto.UserStatusResp, error) {
+	roomId, err := l.roomService.FindUserRoom(uId, claims)
+	if err != nil {
+		return nil, err
+	}
+	resp := &dto.UserStatusResp{UId: uId, Busy: roomId != ""}
+	if requestUid == 0 || requestUid == uId {
+		resp.RoomId = roomId
+	}
+	return resp, nil
 }
 
 func (l RoomLogic) RefuseJoinRoom(req *dto.RefuseJoinRoomReq, claims baseDto.ThkClaims) error {
@@ -171,6 +256,9 @@ func (l RoomLogic) RefuseJoinRoom(req *dto.RefuseJoinRoomReq, claims baseDto.Thk
 	if roomVo == nil {
 		return errorx.ErrRoomNotExisted
 	}
+	if roomVo.IsEnded() {
+		return errorx.ErrRoomEnded
+	}
 	members := make([]int64, 0)
 	for _, p := range roomVo.Participants {
 		if p.UId != req.UId {
@@ -199,6 +287,12 @@ func (l RoomLogic) RoomMemberLeave(req *dto.RoomMemberLeaveReq, claims baseDto.T
 	if roomVo == nil {
 		return nil
 	}
+	// 房主离开时将房主转移给其他成员
+	if roomVo.OwnerId == req.UId {
+		if _, errTransfer := l.roomService.TransferOwner(roomVo.Id, req.UId, req.NewOwnerId, claims); errTransfer != nil {
+			return errTransfer
+		}
+	}
 	members := make([]int64, 0)
 	for _, p := range roomVo.Participants {
 		members = append(members, p.UId)
@@ -213,7 +307,118 @@ func (l RoomLogic) RoomMemberLeave(req *dto.RoomMemberLeaveReq, claims baseDto.T
 	return nil
 }
 
-func (l RoomLogic) KickoffRoomMember(req *dto.KickoffMemberReq, claims baseDto.ThkClaims) error {
+// KickoffRoomMember 踢出成员并加入房间禁止名单, 返回房间与被踢出前的成员信息
+func (l RoomLogic) KickoffRoomMember(req *dto.KickoffMemberReq, claims baseDto.ThkClaims) (*dto.Room, []*dto.Participant, error) {
+	roomVo, err := l.roomService.FindRoomById(req.RoomId, claims)
+	if err != nil {
+		return nil, nil, err
+	}
+	if roomVo == nil {
+		return nil, nil, errorx.ErrRoomNotExisted
+	}
+	if roomVo.IsEnded() {
+		return nil, nil, errorx.ErrRoomEnded
+	}
+	if !roomVo.HasCapability(req.UId, dto.CapKick) {
+		return nil, nil, errorx.ErrNoPermission
+	}
+	// 只能踢出权限角色低于自己的成员
+	roomRole := roomVo.RoomRoleOf(req.UId)
+	for _, uId := range req.KickoffUIds {
+		if roomVo.RoomRoleOf(uId) >= roomRole {
+			return nil, nil, errorx.ErrNoPermission
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	banExpireTime := int64(0)
+	if req.BanDuration > 0 {
+		banExpireTime = now + req.BanDuration*1000
+	}
+	kicked := make([]*dto.Participant, 0, len(req.KickoffUIds))
+	for _, uId := range req.KickoffUIds {
+		participant, errKick := l.roomService.KickMember(roomVo.Id, uId, banExpireTime, claims)
+		if errKick != nil {
+			return nil, nil, errKick
+		}
+		if participant != nil {
+			kicked = append(kicked, participant)
+		}
+	}
+
+	members := l.memberIds(roomVo)
+	s := dto.MakeKickMemberSignal(
+		roomVo.Id, req.Msg, req.UId, now, req.KickoffUIds,
+	)
+	if errPush := l.signalService.PushSignal(s, members, claims); errPush != nil {
+		return nil, nil, errPush
+	}
+
+	// 通知其他成员被踢出成员停止推流
+	for _, p := range kicked {
+		if p.StreamKey == "" {
+			continue
+		}
+		others := make([]int64, 0, len(members))
+		for _, uId := range members {
+			if uId != p.UId {
+				others = append(others, uId)
+			}
+		}
+		stopSignal := dto.MakeParticipantLeaveSignal(roomVo.Id, p.StreamKey, p.UId, now)
+		if errPush := l.signalService.PushSignal(stopSignal, others, claims); errPush != nil {
+			l.appCtx.Logger().WithFields(logrus.Fields(claims)).Error("KickoffRoomMember PushSignal", p.UId, errPush)
+		}
+	}
+	return roomVo, kicked, nil
+}
+
+// IsMemberBanned 成员是否被禁止加入房间
+func (l RoomLogic) IsMemberBanned(roomId string, uId int64, claims baseDto.ThkClaims) (bool, error) {
+	return l.roomService.IsMemberBanned(roomId, uId, claims)
+}
+
+// SaveRoomAdapter 记录房间内创建的WebSocket适配器
+func (l RoomLogic) SaveRoomAdapter(roomId string, adapter *dto.RoomAdapter) error {
+	return l.roomService.SaveRoomAdapter(roomId, adapter)
+}
+
+// FindRoomAdapters 查询房间内的WebSocket适配器
+func (l RoomLogic) FindRoomAdapters(roomId string) ([]*dto.RoomAdapter, error) {
+	return l.roomService.FindRoomAdapters(roomId)
+}
+
+// DeleteRoomAdapters 删除已关闭的WebSocket适配器
+func (l RoomLogic) DeleteRoomAdapters(roomId string, adapterIds []string) error {
+	return l.roomService.DeleteRoomAdapters(roomId, adapterIds)
+}
+
+// Engine 房间引擎, 未配置该引擎时返回nil
+func (l RoomLogic) Engine(name string) room.Engine {
+	return l.roomService.Engine(name)
+}
+
+// UpdateMember 修改成员信息
+func (l RoomLogic) UpdateMember(roomId string, uId int64, update func(participant *dto.Participant), claims baseDto.ThkClaims) error {
+	return l.roomService.UpdateMember(roomId, uId, update, claims)
+}
+
+// AddMemberSubscriptions 记录成员拉流的track
+func (l RoomLogic) AddMemberSubscriptions(roomId string, uId int64, tracks []*dto.ParticipantTrack, claims baseDto.ThkClaims) error {
+	return l.roomService.AddMemberSubscriptions(roomId, uId, tracks, claims)
+}
+
+// GrantModerator 房主设置管理员
+func (l RoomLogic) GrantModerator(req *dto.MemberRoleReq, claims baseDto.ThkClaims) error {
+	return l.changeRoomRole(req, dto.RoomRoleModerator, claims)
+}
+
+// RevokeModerator 房主取消管理员
+func (l RoomLogic) RevokeModerator(req *dto.MemberRoleReq, claims baseDto.ThkClaims) error {
+	return l.changeRoomRole(req, dto.RoomRoleMember, claims)
+}
+
+func (l RoomLogic) changeRoomRole(req *dto.MemberRoleReq, roomRole int, claims baseDto.ThkClaims) error {
 	roomVo, err := l.roomService.FindRoomById(req.RoomId, claims)
 	if err != nil {
 		return err
@@ -221,19 +426,210 @@ func (l RoomLogic) KickoffRoomMember(req *dto.KickoffMemberReq, claims baseDto.T
 	if roomVo == nil {
 		return errorx.ErrRoomNotExisted
 	}
-	hasPermission := roomVo.OwnerId == req.UId
-	if !hasPermission {
+	if !roomVo.HasCapability(req.UId, dto.CapAssignModerator) {
 		return errorx.ErrNoPermission
 	}
-	members := make([]int64, 0)
+	if req.MemberUId == roomVo.OwnerId {
+		return errorx.ErrNoPermission
+	}
+	if errRole := l.roomService.UpdateMemberRoomRole(roomVo.Id, req.MemberUId, roomRole, claims); errRole != nil {
+		return errRole
+	}
+
+	s := dto.MakeRoomRoleChangedSignal(
+		roomVo.Id, req.Msg, req.UId, req.MemberUId, roomRole, time.Now().UnixMilli(),
+	)
+	return l.signalService.PushSignal(s, l.memberIds(roomVo), claims)
+}
+
+// MuteMember 房主/管理员静音/取消静音成员, 成员可以修改自己的静音状态, 返回房间与受影响的track
+func (l RoomLogic) MuteMember(req *dto.MuteMemberReq, claims baseDto.ThkClaims) (*dto.Room, []*dto.ParticipantTrack, error) {
+	if req.Kind != dto.TrackKindAudio && req.Kind != dto.TrackKindVideo {
+		return nil, nil, baseErrorx.ErrParamsError
+	}
+	roomVo, err := l.roomService.FindRoomById(req.RoomId, claims)
+	if err != nil {
+		return nil, nil, err
+	}
+	if roomVo == nil {
+		return nil, nil, errorx.ErrRoomNotExisted
+	}
+	if req.MemberUId != req.UId {
+		if !roomVo.HasCapability(req.UId, dto.CapMuteOthers) {
+			return nil, nil, errorx.ErrNoPermission
+		}
+		if roomVo.RoomRoleOf(req.MemberUId) >= roomVo.RoomRoleOf(req.UId) {
+			return nil, nil, errorx.ErrNoPermission
+		}
+	}
+	participant, errMute := l.roomService.UpdateMemberMute(roomVo.Id, req.MemberUId, req.Kind, req.Muted, claims)
+	if errMute != nil {
+		return nil, nil, errMute
+	}
+
+	s := dto.MakeMemberMutedSignal(
+		roomVo.Id, req.Msg, req.UId, req.MemberUId, req.Kind, req.Muted, time.Now().UnixMilli(),
+	)
+	if errPush := l.signalService.PushSignal(s, l.memberIds(roomVo), claims); errPush != nil {
+		return nil, nil, errPush
+	}
+
+	tracks := make([]*dto.ParticipantTrack, 0)
+	for _, t := range participant.Tracks {
+		if t.Kind == req.Kind {
+			tracks = append(tracks, t)
+		}
+	}
+	return roomVo, tracks, nil
+}
+
+// UpdateMemberTracks 记录成员推流的track
+func (l RoomLogic) UpdateMemberTracks(roomId string, uId int64, tracks []*dto.ParticipantTrack, claims baseDto.ThkClaims) error {
+	return l.roomService.UpdateMemberTracks(roomId, uId, tracks, claims)
+}
+
+// PromoteMember 房主/管理员将观众设置为推流者
+func (l RoomLogic) PromoteMember(req *dto.MemberRoleReq, claims baseDto.ThkClaims) error {
+	return l.changeMemberRole(req, dto.Broadcast, claims)
+}
+
+// DemoteMember 房主/管理员将推流者设置为观众
+func (l RoomLogic) DemoteMember(req *dto.MemberRoleReq, claims baseDto.ThkClaims) error {
+	return l.changeMemberRole(req, dto.Audience, claims)
+}
+
+// MemberHeartbeat 成员定时上报心跳, 超时未上报按离开房间处理
+func (l RoomLogic) MemberHeartbeat(req *dto.MemberHeartbeatReq, claims baseDto.ThkClaims) error {
+	roomVo, err := l.roomService.FindRoomById(req.RoomId, claims)
+	if err != nil {
+		return err
+	}
+	if roomVo == nil {
+		return errorx.ErrRoomNotExisted
+	}
+	for _, p := range roomVo.Participants {
+		if p.UId == req.UId && p.JoinTime > 0 && p.LeaveTime == 0 {
+			return l.roomService.OnMemberHeartbeat(req.RoomId, req.UId, claims)
+		}
+	}
+	return errorx.ErrNotRoomMember
+}
+
+// RaiseHand 观众举手申请发言
+func (l RoomLogic) RaiseHand(req *dto.HandReq, claims baseDto.ThkClaims) error {
+	return l.updateHand(req, true, claims)
+}
+
+// LowerHand 观众取消举手
+func (l RoomLogic) LowerHand(req *dto.HandReq, claims baseDto.ThkClaims) error {
+	return l.updateHand(req, false, claims)
+}
+
+func (l RoomLogic) updateHand(req *dto.HandReq, raise bool, claims baseDto.ThkClaims) error {
+	roomVo, err := l.roomService.FindRoomById(req.RoomId, claims)
+	if err != nil {
+		return err
+	}
+	if roomVo == nil {
+		return errorx.ErrRoomNotExisted
+	}
+	if !dto.IsLiveRoomMode(roomVo.Mode) {
+		return errorx.ErrRoomModeConflict
+	}
+	var participant *dto.Participant
+	for _, p := range roomVo.Participants {
+		if p.UId == req.UId && !p.IsKicked() {
+			participant = p
+			break
+		}
+	}
+	if participant == nil {
+		return errorx.ErrNotRoomMember
+	}
+	// 已经是推流者无需举手
+	if raise && participant.Role == dto.Broadcast {
+		return nil
+	}
+	if !raise && participant.HandTime == 0 {
+		return nil
+	}
+	if errHand := l.roomService.UpdateMemberHand(roomVo.Id, req.UId, raise, claims); errHand != nil {
+		return errHand
+	}
+
+	signalType := dto.HandLowered
+	if raise {
+		signalType = dto.HandRaised
+	}
+	s := dto.MakeSpeakSignal(signalType, roomVo.Id, req.Msg, req.UId, req.UId, time.Now().UnixMilli())
+	return l.signalService.PushSignal(s, l.memberIds(roomVo), claims)
+}
+
+// GrantSpeak 房主/管理员同意成员发言, 成员变为推流者
+func (l RoomLogic) GrantSpeak(req *dto.MemberRoleReq, claims baseDto.ThkClaims) error {
+	return l.changeSpeak(req, true, claims)
+}
+
+// RevokeSpeak 房主/管理员拒绝成员的发言申请或收回发言权限, 成员变为观众
+func (l RoomLogic) RevokeSpeak(req *dto.MemberRoleReq, claims baseDto.ThkClaims) error {
+	return l.changeSpeak(req, false, claims)
+}
+
+func (l RoomLogic) changeSpeak(req *dto.MemberRoleReq, grant bool, claims baseDto.ThkClaims) error {
+	roomVo, err := l.roomService.FindRoomById(req.RoomId, claims)
+	if err != nil {
+		return err
+	}
+	if roomVo == nil {
+		return errorx.ErrRoomNotExisted
+	}
+	if !dto.IsLiveRoomMode(roomVo.Mode) {
+		return errorx.ErrRoomModeConflict
+	}
+	if !roomVo.HasCapability(req.UId, dto.CapPromote) {
+		return errorx.ErrNoPermission
+	}
+	role, signalType := dto.Audience, dto.SpeakRevoked
+	if grant {
+		role, signalType = dto.Broadcast, dto.SpeakGranted
+	}
+	if errRole := l.roomService.UpdateMemberRole(roomVo.Id, req.MemberUId, role, claims); errRole != nil {
+		return errRole
+	}
+	s := dto.MakeSpeakSignal(signalType, roomVo.Id, req.Msg, req.UId, req.MemberUId, time.Now().UnixMilli())
+	return l.signalService.PushSignal(s, l.memberIds(roomVo), claims)
+}
+
+func (l RoomLogic) memberIds(roomVo *dto.Room) []int64 {
+	members := make([]int64, 0, len(roomVo.Participants))
 	for _, p := range roomVo.Participants {
 		members = append(members, p.UId)
 	}
-	s := dto.MakeKickMemberSignal(
-		roomVo.Id, req.Msg, req.UId, time.Now().UnixMilli(), req.KickoffUIds,
+	return members
+}
+
+func (l RoomLogic) changeMemberRole(req *dto.MemberRoleReq, role int, claims baseDto.ThkClaims) error {
+	roomVo, err := l.roomService.FindRoomById(req.RoomId, claims)
+	if err != nil {
+		return err
+	}
+	if roomVo == nil {
+		return errorx.ErrRoomNotExisted
+	}
+	if !dto.IsLiveRoomMode(roomVo.Mode) {
+		return errorx.ErrRoomModeConflict
+	}
+	if !roomVo.HasCapability(req.UId, dto.CapPromote) {
+		return errorx.ErrNoPermission
+	}
+	if errRole := l.roomService.UpdateMemberRole(roomVo.Id, req.MemberUId, role, claims); errRole != nil {
+		return errRole
+	}
+
+	s := dto.MakeMemberRoleChangedSignal(
+		roomVo.Id, req.Msg, req.UId, req.MemberUId, role, time.Now().UnixMilli(),
 	)
-	errPush := l.signalService.PushSignal(s, members, claims)
-	return errPush
+	return l.signalService.PushSignal(s, l.memberIds(roomVo), claims)
 }
 
 func (l RoomLogic) DeleteRoom(req *dto.RoomDelReq, claims baseDto.ThkClaims) error {
@@ -245,6 +641,9 @@ func (l RoomLogic) DeleteRoom(req *dto.RoomDelReq, claims baseDto.ThkClaims) err
 		l.appCtx.Logger().WithFields(logrus.Fields(claims)).Trace("deleteRoom roomVo is nil ", req)
 		return nil
 	}
+	if !roomVo.HasCapability(req.UId, dto.CapEndRoom) {
+		return errorx.ErrNoPermission
+	}
 	if len(roomVo.Participants) > 0 {
 		members := make([]int64, 0)
 		for _, p := range roomVo.Participants {
@@ -258,7 +657,7 @@ func (l RoomLogic) DeleteRoom(req *dto.RoomDelReq, claims baseDto.ThkClaims) err
 			return errPush
 		}
 	}
-	err := l.roomService.DestroyRoom(req.RoomId, claims)
+	err := l.roomService.DestroyRoom(req.RoomId, req.UId, dto.EndReasonOwnerEnded, claims)
 	if err != nil {
 		return err
 	}
@@ -274,6 +673,22 @@ func (l RoomLogic) OnUserLeaveEvent(event *dto.RoomUserLevelEvent, claims baseDt
 	return l.roomService.OnUserLeaveEvent(event, claims)
 }
 
+func (l RoomLogic) OnUserStopPushEvent(event *dto.RoomUserPushStreamEvent, claims baseDto.ThkClaims) error {
+	err := l.roomService.OnUserStopPushEvent(event, claims)
+	if err != nil {
+		l.appCtx.Logger().Error("OnUserStopPushEvent OnUserStopPushEvent", event, err, claims)
+	}
+	return err
+}
+
+func (l RoomLogic) OnStreamHeartbeat(event *dto.RoomUserPushStreamEvent, claims baseDto.ThkClaims) error {
+	err := l.roomService.OnStreamHeartbeat(event, claims)
+	if err != nil {
+		l.appCtx.Logger().Error("OnStreamHeartbeat OnStreamHeartbeat", event, err, claims)
+	}
+	return err
+}
+
 func (l RoomLogic) OnUserPushEvent(event *dto.RoomUserPushStreamEvent, claims baseDto.ThkClaims) error {
 	err := l.roomService.OnUserPushEvent(event, claims)
 	if err != nil {
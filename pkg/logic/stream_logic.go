@@ -8,190 +8,879 @@ import (
 	"github.com/thk-im/thk-im-livecall-server/pkg/app"
 	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
 	"github.com/thk-im/thk-im-livecall-server/pkg/errorx"
-	"github.com/thk-im/thk-im-livecall-server/pkg/sdk"
+	roomSvc "github.com/thk-im/thk-im-livecall-server/pkg/service/room"
+	"github.com/thk-im/thk-im-livecall-server/pkg/service/signal"
 )
 
+// StreamLogic 按房间引擎分发推拉流请求
 type StreamLogic struct {
-	appCtx    *app.Context
-	roomLogic *RoomLogic
+	appCtx        *app.Context
+	roomLogic     *RoomLogic
+	signalService signal.Service
 }
 
 func NewStreamLogic(appCtx *app.Context) *StreamLogic {
 	return &StreamLogic{
-		appCtx:    appCtx,
-		roomLogic: NewRoomLogic(appCtx),
+		appCtx:        appCtx,
+		roomLogic:     NewRoomLogic(appCtx),
+		signalService: signal.NewSignalService(appCtx),
 	}
 }
 
-func (l StreamLogic) api() sdk.SfuApi {
-	if l.appCtx.Context.SdkMap["cloudflare_connect_api"] == nil {
-		return nil
+func (l StreamLogic) PublishStream(req *dto.PublishStreamReq, claims baseDto.ThkClaims) (*dto.PublishStreamResp, error) {
+	room, engine, err := l.checkMember(req.RoomId, req.Uid, claims)
+	if err != nil {
+		l.appCtx.Logger().Error("PublishStream err, ", err)
+		return nil, err
+	}
+	for _, p := range room.Participants {
+		if p.UId == req.Uid && p.Role != dto.Broadcast {
+			l.appCtx.Logger().Error("PublishStream err, ", "not broadcaster", req.Uid)
+			return nil, errorx.ErrNotBroadcaster
+		}
+	}
+	screen := false
+	for _, t := range dto.NameTracks(dto.ParseTracksFromSDP(req.Sdp, ""), req.Tracks) {
+		if t.TrackName == dto.TrackScreen {
+			screen = true
+		}
+	}
+	if screen {
+		if errScreen := l.checkScreenShare(room, req.Uid, req.Override); errScreen != nil {
+			return nil, errScreen
+		}
+	}
+	resp, errPublish := engine.PublishStream(room, req, claims)
+	if errPublish != nil {
+		return nil, errPublish
 	}
-	return l.appCtx.Context.SdkMap["cloudflare_connect_api"].(sdk.SfuApi)
+	resp.MediaParams = room.MediaParams
+	if screen {
+		l.pushScreenShareSignal(dto.ScreenShareStarted, room, req.Uid, resp.SessionId, claims)
+	}
+	return resp, nil
 }
 
-func (l StreamLogic) PublishStream(req *dto.PublishStreamReq, claims baseDto.ThkClaims) (*dto.PublishStreamResp, error) {
-	room, isMember, errMember := l.checkMember(req.RoomId, req.Uid, claims)
-	if errMember != nil {
-		l.appCtx.Logger().Error("PublishStream err, ", errMember)
-		return nil, baseErr.ErrInternalServerError
+// SubscribeStream 拉流, 支持会话的引擎可在一个拉流会话中增量拉取多个推流者的track
+func (l StreamLogic) SubscribeStream(req *dto.SubscribeStreamReq, claims baseDto.ThkClaims) (*dto.SubscribeStreamResp, error) {
+	room, engine, err := l.checkMember(req.RoomId, req.Uid, claims)
+	if err != nil {
+		l.appCtx.Logger().Error("SubscribeStream err, ", err)
+		return nil, err
+	}
+	if _, ok := engine.(roomSvc.SessionUpdater); !ok {
+		if req.IsMulti() {
+			l.appCtx.Logger().Error("SubscribeStream engine not supported, ", room.Engine)
+			return nil, errorx.ErrEngineNotSupported
+		}
+		return engine.SubscribeStream(room, req, claims)
 	}
 
-	if !isMember {
-		l.appCtx.Logger().Error("PublishStream err, ", "not member", req.Uid)
+	var subscriber *dto.Participant
+	for _, p := range room.Participants {
+		if p.UId == req.Uid {
+			subscriber = p
+		}
+	}
+	if req.SubscriberSessionId != "" && !subscriber.OwnsSession(req.SubscriberSessionId) {
+		l.appCtx.Logger().Error("SubscribeStream err, ", "not session owner", req.Uid, req.SubscriberSessionId)
 		return nil, errorx.ErrNoPermission
 	}
+	injected := l.injectedTracks(room)
+	if len(req.Tracks) > 0 {
+		for _, t := range req.Tracks {
+			if t.RemoteSessionId == "" || t.TrackName == "" {
+				return nil, baseErr.ErrParamsError
+			}
+			if !l.isPublisherSession(room, injected, t.RemoteSessionId) {
+				l.appCtx.Logger().Error("SubscribeStream err, ", "not published", t.RemoteSessionId)
+				return nil, errorx.ErrPusherNotExisted
+			}
+		}
+	} else {
+		req.Tracks = l.remoteTracks(room, injected, subscriber, req)
+	}
+	if len(req.Tracks) == 0 && req.SubscriberSessionId == "" {
+		return nil, errorx.ErrPusherNotExisted
+	}
+	return engine.SubscribeStream(room, req, claims)
+}
 
-	resp, err := l.api().CreateSession()
+// UnsubscribeStream 从拉流会话中移除推流者的track, 推流者停止推流或离开房间时由服务端移除, 无需调用
+func (l StreamLogic) UnsubscribeStream(req *dto.UnsubscribeStreamReq, claims baseDto.ThkClaims) (*dto.UnsubscribeStreamResp, error) {
+	room, participant, engine, err := l.checkSession(req.RoomId, req.Uid, req.SessionId, claims)
 	if err != nil {
-		l.appCtx.Logger().Error("PublishStream err, ", err)
-		return nil, baseErr.ErrInternalServerError
+		return nil, err
+	}
+	closer, ok := engine.(roomSvc.TrackCloser)
+	if !ok {
+		l.appCtx.Logger().Error("UnsubscribeStream engine not supported, ", room.Engine)
+		return nil, errorx.ErrEngineNotSupported
 	}
 
-	if resp == nil || resp.SessionID == "" {
-		l.appCtx.Logger().Error("PublishStream resp err", resp)
-		return nil, baseErr.ErrInternalServerError
+	remoteSessionIds := make(map[string]bool)
+	for _, id := range req.RemoteSessionIds {
+		remoteSessionIds[id] = true
+	}
+	tracks := make([]*dto.ParticipantTrack, 0)
+	mids := make(map[string]bool)
+	for _, t := range participant.Subscriptions {
+		if t.SessionId != req.SessionId {
+			continue
+		}
+		if len(remoteSessionIds) > 0 && !remoteSessionIds[t.RemoteSessionId] {
+			continue
+		}
+		tracks = append(tracks, t)
+		mids[t.Mid] = true
 	}
+	resp := &dto.UnsubscribeStreamResp{Mids: make([]string, 0, len(tracks))}
+	if len(tracks) == 0 {
+		return resp, nil
+	}
+	if errClose := closer.CloseTracks(tracks); errClose != nil {
+		return nil, errClose
+	}
+	errUpdate := l.roomLogic.UpdateMember(room.Id, req.Uid, func(p *dto.Participant) {
+		subscriptions := make([]*dto.ParticipantTrack, 0, len(p.Subscriptions))
+		for _, t := range p.Subscriptions {
+			if t.SessionId != req.SessionId || !mids[t.Mid] {
+				subscriptions = append(subscriptions, t)
+			}
+		}
+		p.Subscriptions = subscriptions
+	}, claims)
+	if errUpdate != nil {
+		return nil, errUpdate
+	}
+	for _, t := range tracks {
+		resp.Mids = append(resp.Mids, t.Mid)
+	}
+	return resp, nil
+}
 
-	tracks := dto.ParseTracksFromSDP(req.Sdp, resp.SessionID)
+func (l StreamLogic) UpdateStreamStatus(req *dto.StreamStatusUpdateReq, claims baseDto.ThkClaims) error {
+	room, _, err := l.checkMember(req.RoomId, req.Uid, claims)
+	if err != nil {
+		l.appCtx.Logger().Error("UpdateStreamStatus err, ", err)
+		return err
+	}
+	event := &dto.RoomUserPushStreamEvent{
+		RoomId:    room.Id,
+		UserId:    req.Uid,
+		StreamKey: req.SessionId,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	switch req.Status {
+	case dto.StreamStatusStart:
+		return l.roomLogic.OnUserPushEvent(event, claims)
+	case dto.StreamStatusStop:
+		return l.roomLogic.OnUserStopPushEvent(event, claims)
+	case dto.StreamStatusIng:
+		return l.roomLogic.OnStreamHeartbeat(event, claims)
+	}
+	return nil
+}
 
-	tracksResp, errTracks := l.api().NewTracks(resp.SessionID, &dto.TracksRequest{
-		SessionDescription: &dto.SessionDescription{
-			Type: "offer",
-			SDP:  req.Sdp,
-		},
-		Tracks: tracks,
-	})
+// MuteMember 静音/取消静音成员, 引擎支持时在服务端关闭被静音的track, 取消静音后由客户端重新推流
+func (l StreamLogic) MuteMember(req *dto.MuteMemberReq, claims baseDto.ThkClaims) error {
+	room, tracks, err := l.roomLogic.MuteMember(req, claims)
+	if err != nil {
+		return err
+	}
+	if !req.Muted || len(tracks) == 0 {
+		return nil
+	}
+	if closer, ok := l.roomLogic.Engine(room.Engine).(roomSvc.TrackCloser); ok {
+		return closer.CloseTracks(tracks)
+	}
+	return nil
+}
 
-	if errTracks != nil {
-		l.appCtx.Logger().Error("PublishStream err, ", err)
-		return nil, baseErr.ErrInternalServerError
+// KickoffRoomMember 踢出成员, 引擎支持时在服务端关闭被踢出成员的推拉流
+func (l StreamLogic) KickoffRoomMember(req *dto.KickoffMemberReq, claims baseDto.ThkClaims) error {
+	room, kicked, err := l.roomLogic.KickoffRoomMember(req, claims)
+	if err != nil {
+		return err
+	}
+	closer, ok := l.roomLogic.Engine(room.Engine).(roomSvc.TrackCloser)
+	if !ok {
+		return nil
 	}
+	for _, p := range kicked {
+		tracks := make([]*dto.ParticipantTrack, 0, len(p.Tracks)+len(p.Subscriptions))
+		tracks = append(tracks, p.Tracks...)
+		tracks = append(tracks, p.Subscriptions...)
+		if errClose := closer.CloseTracks(tracks); errClose != nil {
+			l.appCtx.Logger().Error("KickoffRoomMember CloseTracks err, ", p.UId, errClose)
+		}
+	}
+	return nil
+}
 
-	if tracksResp == nil || tracksResp.ErrorCode != "" || resp.SessionID == "" || tracksResp.SessionDescription == nil {
-		l.appCtx.Logger().Error("PublishStream resp err", resp)
-		return nil, baseErr.ErrInternalServerError
+// Renegotiate 提交客户端对sfu offer的answer, 完成重新协商
+func (l StreamLogic) Renegotiate(req *dto.RenegotiateReq, claims baseDto.ThkClaims) (*dto.RenegotiateResp, error) {
+	if req.Sdp == "" {
+		return nil, baseErr.ErrParamsError
 	}
+	room, _, engine, err := l.checkSessionUpdater(req.RoomId, req.Uid, req.SessionId, claims)
+	if err != nil {
+		return nil, err
+	}
+	return engine.Renegotiate(room, req, claims)
+}
 
-	_ = l.roomLogic.OnUserJoinEvent(&dto.RoomUserJoinEvent{
-		RoomId:    room.Id,
-		UserId:    req.Uid,
-		Timestamp: time.Now().UnixMilli(),
+// AddTracks 在已有会话上新增track, 如增加屏幕共享或拉取其他成员的track
+func (l StreamLogic) AddTracks(req *dto.StreamTracksReq, claims baseDto.ThkClaims) (*dto.StreamTracksResp, error) {
+	room, participant, engine, err := l.checkSessionUpdater(req.RoomId, req.Uid, req.SessionId, claims)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Tracks) == 0 && req.Sdp != "" {
+		req.Tracks = l.newLocalTracks(participant, req.SessionId, req.Sdp)
+	}
+	if len(req.Tracks) == 0 {
+		return nil, baseErr.ErrParamsError
+	}
+	injected := l.injectedTracks(room)
+	screen := false
+	for _, t := range req.Tracks {
+		if t.RemoteSessionId == "" {
+			if t.TrackName == dto.TrackScreen {
+				screen = true
+			}
+			if participant.Role != dto.Broadcast {
+				l.appCtx.Logger().Error("AddTracks err, ", "not broadcaster", req.Uid)
+				return nil, errorx.ErrNotBroadcaster
+			}
+			if t.Mid == "" || t.TrackName == "" {
+				return nil, baseErr.ErrParamsError
+			}
+			if t.Kind == dto.TrackKindVideo && !room.MediaParams.VideoEnable() || t.Kind == dto.TrackKindAudio && !room.MediaParams.AudioEnable() {
+				l.appCtx.Logger().Error("AddTracks err, ", "media disabled", room.Id, t.Kind)
+				return nil, baseErr.ErrParamsError
+			}
+		} else if l.publishedTrack(room, injected, t.RemoteSessionId, t.TrackName) == nil {
+			l.appCtx.Logger().Error("AddTracks err, ", "not published", t.RemoteSessionId, t.TrackName)
+			return nil, errorx.ErrPusherNotExisted
+		}
+	}
+	if screen {
+		if errScreen := l.checkScreenShare(room, req.Uid, req.Override); errScreen != nil {
+			return nil, errScreen
+		}
+	}
+
+	resp, errAdd := engine.AddTracks(room, req, claims)
+	if errAdd != nil {
+		return nil, errAdd
+	}
+	errUpdate := l.roomLogic.UpdateMember(room.Id, req.Uid, func(p *dto.Participant) {
+		for _, t := range resp.Tracks {
+			track := &dto.ParticipantTrack{
+				SessionId:       req.SessionId,
+				RemoteSessionId: t.RemoteSessionId,
+				Mid:             t.Mid,
+				Kind:            t.Kind,
+				TrackName:       t.TrackName,
+				PreferredRid:    t.PreferredRid,
+			}
+			if t.RemoteSessionId == "" {
+				track.Rids = t.Rids
+				p.Tracks = append(p.Tracks, track)
+				p.AddPublishedKinds([]*dto.ParticipantTrack{track})
+			} else {
+				p.Subscriptions = append(p.Subscriptions, track)
+			}
+		}
 	}, claims)
+	if errUpdate != nil {
+		return nil, errUpdate
+	}
+	if screen {
+		l.pushScreenShareSignal(dto.ScreenShareStarted, room, req.Uid, req.SessionId, claims)
+	}
+	return resp, nil
+}
 
-	return &dto.PublishStreamResp{SessionId: resp.SessionID, Sdp: tracksResp.SessionDescription.SDP, Type: tracksResp.SessionDescription.Type}, nil
+// UpdateTracks 按mid更新会话内已有的track, 如切换摄像头、更换拉取的track或simulcast层
+func (l StreamLogic) UpdateTracks(req *dto.StreamTracksReq, claims baseDto.ThkClaims) (*dto.StreamTracksResp, error) {
+	room, participant, engine, err := l.checkSessionUpdater(req.RoomId, req.Uid, req.SessionId, claims)
+	if err != nil {
+		return nil, err
+	}
+	return l.updateTracks(room, participant, engine, l.injectedTracks(room), req, claims)
 }
 
-func (l StreamLogic) SubscribeStream(req *dto.SubscribeStreamReq, claims baseDto.ThkClaims) (*dto.SubscribeStreamResp, error) {
-	room, isMember, errMember := l.checkMember(req.RoomId, req.Uid, claims)
-	if errMember != nil {
-		l.appCtx.Logger().Error("SubscribeStream err, ", errMember)
-		return nil, baseErr.ErrInternalServerError
+func (l StreamLogic) updateTracks(room *dto.Room, participant *dto.Participant, engine roomSvc.SessionUpdater, injected []*dto.ParticipantTrack, req *dto.StreamTracksReq, claims baseDto.ThkClaims) (*dto.StreamTracksResp, error) {
+	if len(req.Tracks) == 0 {
+		return nil, baseErr.ErrParamsError
+	}
+	for _, t := range req.Tracks {
+		if l.sessionTrack(participant, req.SessionId, t.Mid) == nil {
+			l.appCtx.Logger().Error("UpdateTracks err, ", "track not existed", req.SessionId, t.Mid)
+			return nil, baseErr.ErrParamsError
+		}
+		if t.RemoteSessionId != "" && t.TrackName != "" && l.publishedTrack(room, injected, t.RemoteSessionId, t.TrackName) == nil {
+			l.appCtx.Logger().Error("UpdateTracks err, ", "not published", t.RemoteSessionId, t.TrackName)
+			return nil, errorx.ErrPusherNotExisted
+		}
 	}
 
-	if !isMember {
-		l.appCtx.Logger().Error("SubscribeStream err, ", "not member", req.Uid)
-		return nil, errorx.ErrNoPermission
+	resp, errUpdateTracks := engine.UpdateTracks(room, req, claims)
+	if errUpdateTracks != nil {
+		return nil, errUpdateTracks
 	}
+	errUpdate := l.roomLogic.UpdateMember(room.Id, req.Uid, func(p *dto.Participant) {
+		for _, t := range req.Tracks {
+			track := l.sessionTrack(p, req.SessionId, t.Mid)
+			if track == nil {
+				continue
+			}
+			if t.TrackName != "" {
+				track.TrackName = t.TrackName
+			}
+			if t.Kind != "" {
+				track.Kind = t.Kind
+			}
+			if t.RemoteSessionId != "" {
+				track.RemoteSessionId = t.RemoteSessionId
+			}
+			if t.PreferredRid != "" {
+				track.PreferredRid = t.PreferredRid
+			}
+		}
+	}, claims)
+	if errUpdate != nil {
+		return nil, errUpdate
+	}
+	return resp, nil
+}
 
-	resp, err := l.api().CreateSession()
+// SetSimulcastLayer 切换拉取的simulcast层, 如宫格小窗拉取low, 主讲人拉取high
+func (l StreamLogic) SetSimulcastLayer(req *dto.SimulcastLayerReq, claims baseDto.ThkClaims) (*dto.StreamTracksResp, error) {
+	if req.RemoteSessionId == "" || req.PreferredRid == "" {
+		return nil, baseErr.ErrParamsError
+	}
+	room, participant, engine, err := l.checkSessionUpdater(req.RoomId, req.Uid, req.SessionId, claims)
 	if err != nil {
-		l.appCtx.Logger().Error("SubscribeStream err, ", err)
-		return nil, baseErr.ErrInternalServerError
+		return nil, err
+	}
+	tracksReq := &dto.StreamTracksReq{
+		RoomId:    req.RoomId,
+		SessionId: req.SessionId,
+		Tracks:    make([]*dto.StreamTrack, 0),
+		Uid:       req.Uid,
+	}
+	injected := l.injectedTracks(room)
+	for _, t := range participant.Subscriptions {
+		if t.SessionId != req.SessionId || t.RemoteSessionId != req.RemoteSessionId || t.Kind != dto.TrackKindVideo {
+			continue
+		}
+		if req.TrackName != "" && t.TrackName != req.TrackName {
+			continue
+		}
+		published := l.publishedTrack(room, injected, t.RemoteSessionId, t.TrackName)
+		if published != nil && len(published.Rids) > 0 && !published.HasRid(req.PreferredRid) {
+			l.appCtx.Logger().Error("SetSimulcastLayer err, ", "rid not published", t.TrackName, req.PreferredRid)
+			return nil, baseErr.ErrParamsError
+		}
+		tracksReq.Tracks = append(tracksReq.Tracks, &dto.StreamTrack{
+			Mid:             t.Mid,
+			Kind:            t.Kind,
+			TrackName:       t.TrackName,
+			RemoteSessionId: t.RemoteSessionId,
+			PreferredRid:    req.PreferredRid,
+		})
 	}
+	if len(tracksReq.Tracks) == 0 {
+		return nil, errorx.ErrPusherNotExisted
+	}
+	return l.updateTracks(room, participant, engine, injected, tracksReq, claims)
+}
 
-	if resp == nil || resp.SessionID == "" {
-		l.appCtx.Logger().Error("SubscribeStream resp err", resp)
-		return nil, baseErr.ErrInternalServerError
+// CloseStreamTracks 关闭自己推流会话中的track, 关闭屏幕共享时通知其他成员
+func (l StreamLogic) CloseStreamTracks(req *dto.CloseStreamTracksReq, claims baseDto.ThkClaims) error {
+	room, participant, engine, err := l.checkSession(req.RoomId, req.Uid, req.SessionId, claims)
+	if err != nil {
+		return err
+	}
+	closer, ok := engine.(roomSvc.TrackCloser)
+	if !ok {
+		l.appCtx.Logger().Error("CloseStreamTracks engine not supported, ", room.Engine)
+		return errorx.ErrEngineNotSupported
 	}
+	mids := make(map[string]bool)
+	for _, mid := range req.Mids {
+		mids[mid] = true
+	}
+	tracks := make([]*dto.ParticipantTrack, 0)
+	screen := false
+	for _, t := range participant.Tracks {
+		if t.SessionId == req.SessionId && mids[t.Mid] {
+			tracks = append(tracks, t)
+			if t.TrackName == dto.TrackScreen {
+				screen = true
+			}
+		}
+	}
+	if len(tracks) == 0 {
+		return nil
+	}
+	if errClose := closer.CloseTracks(tracks); errClose != nil {
+		return errClose
+	}
+	errUpdate := l.roomLogic.UpdateMember(room.Id, req.Uid, func(p *dto.Participant) {
+		remain := make([]*dto.ParticipantTrack, 0, len(p.Tracks))
+		for _, t := range p.Tracks {
+			if t.SessionId != req.SessionId || !mids[t.Mid] {
+				remain = append(remain, t)
+			}
+		}
+		p.Tracks = remain
+	}, claims)
+	if errUpdate != nil {
+		return errUpdate
+	}
+	if screen {
+		l.pushScreenShareSignal(dto.ScreenShareStopped, room, req.Uid, req.SessionId, claims)
+	}
+	return nil
+}
 
-	tracks := make([]dto.TrackObject, 0)
-	if room.Mode == dto.ModeVideo || room.Mode == dto.ModeVideoRoom {
-		tracks = append(tracks, dto.TrackObject{
-			Location:  "remote",
-			SessionID: req.SessionId,
-			TrackName: "camera",
+// checkScreenShare 房间内同时只允许一人共享屏幕, 管理员可指定override同时共享
+func (l StreamLogic) checkScreenShare(room *dto.Room, uId int64, override bool) error {
+	if override && room.HasCapability(uId, dto.CapMuteOthers) {
+		return nil
+	}
+	for _, p := range room.Participants {
+		if p.UId == uId || p.LeaveTime > 0 || p.KickTime > 0 {
+			continue
+		}
+		if p.ScreenSessionId() != "" {
+			l.appCtx.Logger().Error("checkScreenShare err, ", "screen shared by", p.UId)
+			return errorx.ErrScreenShareExisted
+		}
+	}
+	return nil
+}
+
+func (l StreamLogic) pushScreenShareSignal(signalType int, room *dto.Room, uId int64, sessionId string, claims baseDto.ThkClaims) {
+	s := dto.MakeScreenShareSignal(signalType, room.Id, uId, sessionId, time.Now().UnixMilli())
+	if errPush := l.signalService.PushSignal(s, l.otherMemberIds(room, uId), claims); errPush != nil {
+		l.appCtx.Logger().Error("pushScreenShareSignal err, ", errPush)
+	}
+}
+
+// checkSessionUpdater 校验会话归属以及引擎是否支持会话更新
+func (l StreamLogic) checkSessionUpdater(roomId string, uId int64, sessionId string, claims baseDto.ThkClaims) (*dto.Room, *dto.Participant, roomSvc.SessionUpdater, error) {
+	room, participant, engine, err := l.checkSession(roomId, uId, sessionId, claims)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	updater, ok := engine.(roomSvc.SessionUpdater)
+	if !ok {
+		l.appCtx.Logger().Error("checkSessionUpdater engine not supported, ", room.Engine)
+		return nil, nil, nil, errorx.ErrEngineNotSupported
+	}
+	return room, participant, updater, nil
+}
+
+// newLocalTracks 从offer中解析该会话尚未记录的推流track
+func (l StreamLogic) newLocalTracks(participant *dto.Participant, sessionId, sdp string) []*dto.StreamTrack {
+	tracks := make([]*dto.StreamTrack, 0)
+	for _, t := range dto.ParseTracksFromSDP(sdp, sessionId) {
+		if l.sessionTrack(participant, sessionId, t.Mid) != nil {
+			continue
+		}
+		tracks = append(tracks, &dto.StreamTrack{
+			Mid:       t.Mid,
+			Kind:      t.Kind,
+			TrackName: t.TrackName,
+			Rids:      t.Rids,
 		})
 	}
-	tracks = append(tracks, dto.TrackObject{
-		Location:  "remote",
-		SessionID: req.SessionId,
-		TrackName: "mic",
-	})
+	return tracks
+}
 
-	tracksResp, tracksError := l.api().NewTracks(resp.SessionID, &dto.TracksRequest{
-		SessionDescription: &dto.SessionDescription{
-			SDP:  req.Sdp,
-			Type: "offer",
-		},
-		Tracks: tracks,
-	})
+func (l StreamLogic) sessionTrack(participant *dto.Participant, sessionId, mid string) *dto.ParticipantTrack {
+	for _, t := range participant.Tracks {
+		if t.SessionId == sessionId && t.Mid == mid {
+			return t
+		}
+	}
+	for _, t := range participant.Subscriptions {
+		if t.SessionId == sessionId && t.Mid == mid {
+			return t
+		}
+	}
+	return nil
+}
 
-	if tracksError != nil {
-		l.appCtx.Logger().Error("SubscribeStream err, ", tracksError)
-		return nil, baseErr.ErrInternalServerError
+// remoteTracks 按请求的推流会话列出需要拉取的track, 跳过自己的推流以及拉流会话中已拉取的track
+func (l StreamLogic) remoteTracks(room *dto.Room, injected []*dto.ParticipantTrack, subscriber *dto.Participant, req *dto.SubscribeStreamReq) []*dto.StreamTrack {
+	sessionIds := make([]string, 0)
+	if req.All {
+		for _, p := range room.Participants {
+			if p.UId == req.Uid || p.LeaveTime > 0 || p.KickTime > 0 {
+				continue
+			}
+			sessionIds = append(sessionIds, l.publishSessionIds(p)...)
+		}
+		for _, t := range injected {
+			sessionIds = append(sessionIds, t.SessionId)
+		}
+	} else {
+		sessionIds = append(sessionIds, req.SessionIds...)
+		if req.SessionId != "" {
+			sessionIds = append(sessionIds, req.SessionId)
+		}
 	}
-	if tracksResp == nil || tracksResp.ErrorCode != "" || tracksResp.SessionDescription == nil {
-		l.appCtx.Logger().Error("SubscribeStream resp err", tracksResp)
-		return nil, baseErr.ErrInternalServerError
+
+	tracks := make([]*dto.StreamTrack, 0)
+	added := make(map[string]bool)
+	for _, sessionId := range sessionIds {
+		if added[sessionId] || l.isOwnPublishSession(subscriber, sessionId) {
+			continue
+		}
+		added[sessionId] = true
+		for _, t := range l.sessionPublishedTracks(room, injected, sessionId) {
+			if !l.matchTrackName(t.TrackName, req.TrackNames) {
+				continue
+			}
+			subscribed := false
+			for _, s := range subscriber.Subscriptions {
+				if s.SessionId == req.SubscriberSessionId && s.RemoteSessionId == sessionId && s.TrackName == t.TrackName {
+					subscribed = true
+				}
+			}
+			if subscribed {
+				continue
+			}
+			if req.PreferredRid != "" && t.Kind == dto.TrackKindVideo {
+				for _, rid := range t.Rids {
+					if rid == req.PreferredRid {
+						t.PreferredRid = rid
+					}
+				}
+			}
+			tracks = append(tracks, t)
+		}
 	}
+	return tracks
+}
 
-	res := &dto.SubscribeStreamResp{
-		Renegotiation: tracksResp.RequiresImmediateRenegotiation,
+func (l StreamLogic) matchTrackName(trackName string, trackNames []string) bool {
+	if len(trackNames) == 0 {
+		return true
 	}
-	if tracksResp.SessionDescription != nil {
-		res.Sdp = tracksResp.SessionDescription.SDP
-		res.Type = tracksResp.SessionDescription.Type
+	for _, name := range trackNames {
+		if name == trackName {
+			return true
+		}
 	}
-	return res, nil
+	return false
 }
 
-func (l StreamLogic) UpdateStreamStatus(req *dto.StreamStatusUpdateReq, claims baseDto.ThkClaims) error {
-	_, isMember, errMember := l.checkMember(req.RoomId, req.Uid, claims)
-	if errMember != nil {
-		l.appCtx.Logger().Error("UpdateStreamStatus err, ", errMember)
-		return baseErr.ErrInternalServerError
+// sessionPublishedTracks 推流会话已发布的track, 未记录track时按房间模式取默认的camera/mic
+func (l StreamLogic) sessionPublishedTracks(room *dto.Room, injected []*dto.ParticipantTrack, sessionId string) []*dto.StreamTrack {
+	tracks := make([]*dto.StreamTrack, 0)
+	for _, p := range room.Participants {
+		for _, t := range p.Tracks {
+			if t.SessionId == sessionId {
+				tracks = append(tracks, &dto.StreamTrack{
+					Kind:            t.Kind,
+					TrackName:       t.TrackName,
+					RemoteSessionId: sessionId,
+					Rids:            t.Rids,
+				})
+			}
+		}
 	}
+	if len(tracks) > 0 {
+		return tracks
+	}
+	for _, t := range injected {
+		if t.SessionId == sessionId {
+			tracks = append(tracks, &dto.StreamTrack{Kind: t.Kind, TrackName: t.TrackName, RemoteSessionId: sessionId})
+		}
+	}
+	if len(tracks) > 0 || !l.isPublisherSession(room, injected, sessionId) {
+		return tracks
+	}
+	if room.Mode == dto.ModeVideo || room.Mode == dto.ModeVideoRoom {
+		tracks = append(tracks, &dto.StreamTrack{Kind: dto.TrackKindVideo, TrackName: dto.TrackCamera, RemoteSessionId: sessionId})
+	}
+	tracks = append(tracks, &dto.StreamTrack{Kind: dto.TrackKindAudio, TrackName: dto.TrackMic, RemoteSessionId: sessionId})
+	return tracks
+}
 
-	if !isMember {
-		l.appCtx.Logger().Error("UpdateStreamStatus err, ", "not member", req.Uid)
-		return errorx.ErrNoPermission
+// publishSessionIds 成员的推流会话
+func (l StreamLogic) publishSessionIds(participant *dto.Participant) []string {
+	sessionIds := make([]string, 0)
+	existed := make(map[string]bool)
+	for _, t := range participant.Tracks {
+		if !existed[t.SessionId] {
+			existed[t.SessionId] = true
+			sessionIds = append(sessionIds, t.SessionId)
+		}
+	}
+	if participant.StreamKey != "" && !existed[participant.StreamKey] {
+		sessionIds = append(sessionIds, participant.StreamKey)
+	}
+	return sessionIds
+}
+
+func (l StreamLogic) isOwnPublishSession(participant *dto.Participant, sessionId string) bool {
+	for _, id := range l.publishSessionIds(participant) {
+		if id == sessionId {
+			return true
+		}
+	}
+	return false
+}
+
+func (l StreamLogic) isPublisherSession(room *dto.Room, injected []*dto.ParticipantTrack, sessionId string) bool {
+	for _, p := range room.Participants {
+		if l.isOwnPublishSession(p, sessionId) {
+			return true
+		}
+	}
+	for _, t := range injected {
+		if t.SessionId == sessionId {
+			return true
+		}
+	}
+	return false
+}
+
+// injectedTracks WebSocket推入房间的音频track, 注入会话只记录在房间适配器中, 每个请求只读取一次
+func (l StreamLogic) injectedTracks(room *dto.Room) []*dto.ParticipantTrack {
+	adapters, err := l.roomLogic.FindRoomAdapters(room.Id)
+	if err != nil {
+		l.appCtx.Logger().Error("injectedTracks err, ", room.Id, err)
+		return nil
+	}
+	tracks := make([]*dto.ParticipantTrack, 0)
+	for _, a := range adapters {
+		if a.Direction != dto.AdapterInject || a.SessionId == "" {
+			continue
+		}
+		trackName := a.TrackName
+		if trackName == "" {
+			trackName = dto.TrackMic
+		}
+		tracks = append(tracks, &dto.ParticipantTrack{SessionId: a.SessionId, Kind: dto.TrackKindAudio, TrackName: trackName})
+	}
+	return tracks
+}
+
+// publishedTrack 查找房间成员或音频注入在推流会话中发布的track
+func (l StreamLogic) publishedTrack(room *dto.Room, injected []*dto.ParticipantTrack, sessionId, trackName string) *dto.ParticipantTrack {
+	for _, p := range room.Participants {
+		for _, t := range p.Tracks {
+			if t.SessionId == sessionId && t.TrackName == trackName {
+				return t
+			}
+		}
+	}
+	for _, t := range injected {
+		if t.SessionId == sessionId && t.TrackName == trackName {
+			return t
+		}
+	}
+	return nil
+}
+
+func (l StreamLogic) EstablishDataChannelTransport(req *dto.DataChannelTransportReq, claims baseDto.ThkClaims) (*dto.DataChannelTransportResp, error) {
+	room, _, engine, err := l.checkDataChannelSession(req.RoomId, req.Uid, req.SessionId, claims)
+	if err != nil {
+		return nil, err
 	}
+	return engine.EstablishDataChannelTransport(room, req, claims)
+}
 
-	if req.Status == "start" {
-		return l.roomLogic.OnUserPushEvent(&dto.RoomUserPushStreamEvent{
-			RoomId:    req.RoomId,
-			UserId:    req.Uid,
-			StreamKey: req.SessionId,
-			Timestamp: time.Now().UnixMilli(),
-		}, claims)
-	} else if req.Status == "stop" {
+// PublishDataChannel 发布数据通道, 并通知其他成员订阅
+func (l StreamLogic) PublishDataChannel(req *dto.PublishDataChannelReq, claims baseDto.ThkClaims) (*dto.DataChannelResp, error) {
+	if req.Name == "" {
+		return nil, baseErr.ErrParamsError
+	}
+	room, _, engine, err := l.checkDataChannelSession(req.RoomId, req.Uid, req.SessionId, claims)
+	if err != nil {
+		return nil, err
+	}
+	resp, errPublish := engine.PublishDataChannel(room, req, claims)
+	if errPublish != nil {
+		return nil, errPublish
+	}
+	errUpdate := l.roomLogic.UpdateMember(room.Id, req.Uid, func(p *dto.Participant) {
+		p.DataChannels = append(p.DataChannels, &dto.ParticipantDataChannel{
+			SessionId: req.SessionId,
+			Name:      resp.Name,
+			Id:        resp.Id,
+		})
+	}, claims)
+	if errUpdate != nil {
+		return nil, errUpdate
+	}
+	s := dto.MakeDataChannelSignal(dto.DataChannelPublished, room.Id, req.Uid, req.SessionId, req.Name, time.Now().UnixMilli())
+	if errPush := l.signalService.PushSignal(s, l.otherMemberIds(room, req.Uid), claims); errPush != nil {
+		l.appCtx.Logger().Error("PublishDataChannel PushSignal err, ", errPush)
+	}
+	return resp, nil
+}
 
-	} else if req.Status == "ing" {
+// SubscribeDataChannel 订阅房间内其他成员发布的数据通道
+func (l StreamLogic) SubscribeDataChannel(req *dto.SubscribeDataChannelReq, claims baseDto.ThkClaims) (*dto.DataChannelResp, error) {
+	room, _, engine, err := l.checkDataChannelSession(req.RoomId, req.Uid, req.SessionId, claims)
+	if err != nil {
+		return nil, err
+	}
+	published := false
+	for _, p := range room.Participants {
+		for _, c := range p.DataChannels {
+			if c.SessionId == req.RemoteSessionId && c.Name == req.Name {
+				published = true
+			}
+		}
+	}
+	if !published {
+		l.appCtx.Logger().Error("SubscribeDataChannel err, ", "not published", req.RemoteSessionId, req.Name)
+		return nil, errorx.ErrPusherNotExisted
+	}
+	return engine.SubscribeDataChannel(room, req, claims)
+}
 
+// CloseDataChannel 关闭数据通道, 关闭自己发布的通道时通知其他成员
+func (l StreamLogic) CloseDataChannel(req *dto.CloseDataChannelReq, claims baseDto.ThkClaims) error {
+	room, participant, engine, err := l.checkDataChannelSession(req.RoomId, req.Uid, req.SessionId, claims)
+	if err != nil {
+		return err
+	}
+	if len(req.Names) == 0 {
+		return nil
+	}
+	if errClose := engine.CloseDataChannel(room, req, claims); errClose != nil {
+		return errClose
+	}
+
+	closed := make(map[string]bool)
+	for _, c := range participant.DataChannels {
+		if c.SessionId != req.SessionId {
+			continue
+		}
+		for _, name := range req.Names {
+			if c.Name == name {
+				closed[name] = true
+			}
+		}
+	}
+	if len(closed) == 0 {
+		return nil
+	}
+	errUpdate := l.roomLogic.UpdateMember(room.Id, req.Uid, func(p *dto.Participant) {
+		channels := make([]*dto.ParticipantDataChannel, 0, len(p.DataChannels))
+		for _, c := range p.DataChannels {
+			if c.SessionId != req.SessionId || !closed[c.Name] {
+				channels = append(channels, c)
+			}
+		}
+		p.DataChannels = channels
+	}, claims)
+	if errUpdate != nil {
+		return errUpdate
+	}
+	now := time.Now().UnixMilli()
+	for name := range closed {
+		s := dto.MakeDataChannelSignal(dto.DataChannelClosed, room.Id, req.Uid, req.SessionId, name, now)
+		if errPush := l.signalService.PushSignal(s, l.otherMemberIds(room, req.Uid), claims); errPush != nil {
+			l.appCtx.Logger().Error("CloseDataChannel PushSignal err, ", errPush)
+		}
 	}
 	return nil
 }
 
-func (l StreamLogic) checkMember(roomId string, uId int64, claims baseDto.ThkClaims) (*dto.Room, bool, error) {
+// checkDataChannelSession 校验成员身份、会话归属以及引擎是否支持数据通道
+func (l StreamLogic) checkDataChannelSession(roomId string, uId int64, sessionId string, claims baseDto.ThkClaims) (*dto.Room, *dto.Participant, roomSvc.DataChannel, error) {
+	room, participant, engine, err := l.checkSession(roomId, uId, sessionId, claims)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	dataChannel, ok := engine.(roomSvc.DataChannel)
+	if !ok {
+		l.appCtx.Logger().Error("checkDataChannelSession engine not supported, ", room.Engine)
+		return nil, nil, nil, errorx.ErrEngineNotSupported
+	}
+	return room, participant, dataChannel, nil
+}
+
+// checkSession 在checkMember基础上校验sfu会话属于该成员
+func (l StreamLogic) checkSession(roomId string, uId int64, sessionId string, claims baseDto.ThkClaims) (*dto.Room, *dto.Participant, roomSvc.Engine, error) {
+	room, engine, err := l.checkMember(roomId, uId, claims)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, p := range room.Participants {
+		if p.UId == uId && p.OwnsSession(sessionId) {
+			return room, p, engine, nil
+		}
+	}
+	l.appCtx.Logger().Error("checkSession err, ", "not session owner", uId, sessionId)
+	return nil, nil, nil, errorx.ErrNoPermission
+}
+
+func (l StreamLogic) otherMemberIds(room *dto.Room, uId int64) []int64 {
+	uIds := make([]int64, 0, len(room.Participants))
+	for _, p := range room.Participants {
+		if p.UId != uId {
+			uIds = append(uIds, p.UId)
+		}
+	}
+	return uIds
+}
+
+// checkMember 校验用户是房间成员, 并返回房间引擎对应的推拉流实现
+func (l StreamLogic) checkMember(roomId string, uId int64, claims baseDto.ThkClaims) (*dto.Room, roomSvc.Engine, error) {
 	room, errRoom := l.roomLogic.QueryRoom(roomId, claims)
 	if errRoom != nil {
 		l.appCtx.Logger().Error("checkMember err, ", errRoom)
-		return nil, false, baseErr.ErrInternalServerError
+		return nil, nil, baseErr.ErrInternalServerError
 	}
 
 	if room == nil {
 		l.appCtx.Logger().Error("checkMember room is nil, ", roomId)
-		return nil, false, errorx.ErrRoomNotExisted
+		return nil, nil, errorx.ErrRoomNotExisted
+	}
+	if room.IsEnded() {
+		return nil, nil, errorx.ErrRoomEnded
 	}
 
 	isMember := false
 	for _, p := range room.Participants {
-		if p.UId == uId {
+		// 被踢出的成员解禁后需重新加入
+		if p.UId == uId && !p.IsKicked() {
 			isMember = true
 			break
 		}
 	}
+	if !isMember {
+		l.appCtx.Logger().Error("checkMember err, ", "not member", uId)
+		return nil, nil, errorx.ErrNoPermission
+	}
+	banned, errBan := l.roomLogic.IsMemberBanned(roomId, uId, claims)
+	if errBan != nil {
+		l.appCtx.Logger().Error("checkMember err, ", errBan)
+		return nil, nil, baseErr.ErrInternalServerError
+	}
+	if banned {
+		l.appCtx.Logger().Error("checkMember err, ", "banned", uId)
+		return nil, nil, errorx.ErrMemberBanned
+	}
 
-	return room, isMember, nil
+	engine := l.roomLogic.Engine(room.Engine)
+	if engine == nil {
+		l.appCtx.Logger().Error("checkMember engine not supported, ", room.Engine)
+		return nil, nil, errorx.ErrEngineNotSupported
+	}
+	return room, engine, nil
 }
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/thk-im/thk-im-base-server/snowflake"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	// CallLeg 参与人通话记录, 按用户id分表
+	CallLeg struct {
+		RoomId     string `gorm:"column:room_id" json:"room_id"`
+		UId        int64  `gorm:"column:u_id" json:"u_id"`
+		SessionId  int64  `gorm:"column:session_id" json:"session_id"`
+		OwnerId    int64  `gorm:"column:owner_id" json:"owner_id"`
+		Mode       int    `gorm:"column:mode" json:"mode"`
+		Engine     string `gorm:"column:engine" json:"engine"`
+		Role       int    `gorm:"column:role" json:"role"`
+		JoinTime   int64  `gorm:"column:join_time" json:"join_time"`
+		LeaveTime  int64  `gorm:"column:leave_time" json:"leave_time"`
+		RefuseTime int64  `gorm:"column:refuse_time" json:"refuse_time"`
+		KickTime   int64  `gorm:"column:kick_time" json:"kick_time"`
+		Duration   int64  `gorm:"column:duration" json:"duration"`
+		CreateTime int64  `gorm:"column:create_time" json:"create_time"`
+		EndTime    int64  `gorm:"column:end_time" json:"end_time"`
+	}
+
+	CallLegModel interface {
+		// InsertCallLegs 保存参与人通话记录, 房间id和用户id重复时忽略
+		InsertCallLegs(legs []*CallLeg) error
+		// FindCallLegsByUser 按创建时间倒序查询用户通话记录
+		FindCallLegsByUser(uId int64, offset, count int) ([]*CallLeg, error)
+		// ExistsSessionCallLeg 用户是否参与过会话的通话
+		ExistsSessionCallLeg(uId, sessionId int64) (bool, error)
+	}
+
+	defaultCallLegModel struct {
+		db            *gorm.DB
+		logger        *logrus.Entry
+		snowflakeNode *snowflake.Node
+		shards        int64
+	}
+)
+
+func (d defaultCallLegModel) InsertCallLegs(legs []*CallLeg) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		for _, leg := range legs {
+			err := tx.Table(d.genCallLegTableName(leg.UId)).
+				Clauses(clause.Insert{Modifier: "IGNORE"}).
+				Create(leg).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (d defaultCallLegModel) FindCallLegsByUser(uId int64, offset, count int) ([]*CallLeg, error) {
+	legs := make([]*CallLeg, 0)
+	err := d.db.Table(d.genCallLegTableName(uId)).
+		Where("u_id = ?", uId).
+		Order("create_time desc").
+		Offset(offset).
+		Limit(count).
+		Find(&legs).Error
+	return legs, err
+}
+
+func (d defaultCallLegModel) ExistsSessionCallLeg(uId, sessionId int64) (bool, error) {
+	count := int64(0)
+	err := d.db.Table(d.genCallLegTableName(uId)).
+		Where("u_id = ? and session_id = ?", uId, sessionId).
+		Limit(1).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (d defaultCallLegModel) genCallLegTableName(uId int64) string {
+	return "live_call_leg_" + shardSuffix(uId, d.shards)
+}
+
+func NewCallLegModel(db *gorm.DB, logger *logrus.Entry, snowflakeNode *snowflake.Node, shards int64) CallLegModel {
+	return defaultCallLegModel{db: db, logger: logger, snowflakeNode: snowflakeNode, shards: shards}
+}
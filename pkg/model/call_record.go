@@ -0,0 +1,64 @@
+package model
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/thk-im/thk-im-base-server/snowflake"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	// CallRecord 通话记录, 按会话id分表
+	CallRecord struct {
+		RoomId      string `gorm:"column:room_id" json:"room_id"`
+		SessionId   int64  `gorm:"column:session_id" json:"session_id"`
+		OwnerId     int64  `gorm:"column:owner_id" json:"owner_id"`
+		Mode        int    `gorm:"column:mode" json:"mode"`
+		Engine      string `gorm:"column:engine" json:"engine"`
+		Accepted    int    `gorm:"column:accepted" json:"accepted"`
+		AcceptTime  int64  `gorm:"column:accept_time" json:"accept_time"`
+		Duration    int64  `gorm:"column:duration" json:"duration"`
+		MemberCount int    `gorm:"column:member_count" json:"member_count"`
+		CreateTime  int64  `gorm:"column:create_time" json:"create_time"`
+		EndTime     int64  `gorm:"column:end_time" json:"end_time"`
+	}
+
+	CallRecordModel interface {
+		// InsertCallRecord 保存通话记录, 房间id重复时忽略
+		InsertCallRecord(record *CallRecord) error
+		// FindCallRecordsBySession 按创建时间倒序查询会话通话记录
+		FindCallRecordsBySession(sessionId int64, offset, count int) ([]*CallRecord, error)
+	}
+
+	defaultCallRecordModel struct {
+		db            *gorm.DB
+		logger        *logrus.Entry
+		snowflakeNode *snowflake.Node
+		shards        int64
+	}
+)
+
+func (d defaultCallRecordModel) InsertCallRecord(record *CallRecord) error {
+	return d.db.Table(d.genCallRecordTableName(record.SessionId)).
+		Clauses(clause.Insert{Modifier: "IGNORE"}).
+		Create(record).Error
+}
+
+func (d defaultCallRecordModel) FindCallRecordsBySession(sessionId int64, offset, count int) ([]*CallRecord, error) {
+	records := make([]*CallRecord, 0)
+	err := d.db.Table(d.genCallRecordTableName(sessionId)).
+		Where("session_id = ?", sessionId).
+		Order("create_time desc").
+		Offset(offset).
+		Limit(count).
+		Find(&records).Error
+	return records, err
+}
+
+func (d defaultCallRecordModel) genCallRecordTableName(sessionId int64) string {
+	return "live_call_record_" + shardSuffix(sessionId, d.shards)
+}
+
+func NewCallRecordModel(db *gorm.DB, logger *logrus.Entry, snowflakeNode *snowflake.Node, shards int64) CallRecordModel {
+	return defaultCallRecordModel{db: db, logger: logger, snowflakeNode: snowflakeNode, shards: shards}
+}
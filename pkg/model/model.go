@@ -0,0 +1,11 @@
+package model
+
+import "fmt"
+
+// shardSuffix 分表后缀, 与loader.LoadTables建表规则一致, 只有一张表时后缀为""
+func shardSuffix(key, shards int64) string {
+	if shards <= 1 {
+		return ""
+	}
+	return fmt.Sprintf("%d", key%shards)
+}
@@ -28,6 +28,16 @@ type (
 		Renegotiate(sessionId string, req *dto.RenegotiateRequest) (*dto.RenegotiateResponse, error)
 		// GetSessionState 查询Session状态
 		GetSessionState(sessionId string) (*dto.GetSessionStateResponse, error)
+		// EstablishDataChannelsTransport 建立数据通道传输, 会话首次使用数据通道时调用
+		EstablishDataChannelsTransport(sessionId string, req *dto.EstablishDataChannelsTransportRequest) (*dto.EstablishDataChannelsTransportResponse, error)
+		// NewDataChannels 创建数据通道 local为发布, remote为订阅其他会话的数据通道
+		NewDataChannels(sessionId string, req *dto.DataChannelsRequest) (*dto.DataChannelsResponse, error)
+		// CloseDataChannels 关闭数据通道
+		CloseDataChannels(sessionId string, req *dto.CloseDataChannelsRequest) (*dto.CloseDataChannelsResponse, error)
+		// NewAdapter 创建WebSocket适配器, remote将track转发到WebSocket, local从WebSocket接收音频推入sfu
+		NewAdapter(req *dto.NewAdapterRequest) (*dto.NewAdapterResponse, error)
+		// CloseAdapter 关闭WebSocket适配器
+		CloseAdapter(req *dto.CloseAdapterRequest) (*dto.CloseAdapterResponse, error)
 	}
 
 	defaultSfuApi struct {
@@ -194,6 +204,136 @@ func (d defaultSfuApi) GetSessionState(sessionId string) (*dto.GetSessionStateRe
 	return &result, nil
 }
 
+func (d defaultSfuApi) EstablishDataChannelsTransport(sessionId string, req *dto.EstablishDataChannelsTransportRequest) (*dto.EstablishDataChannelsTransportResponse, error) {
+	url := fmt.Sprintf("%s/apps/%s/sessions/%s/datachannels/establish", d.endpoint, d.appId, sessionId)
+
+	res := &dto.EstablishDataChannelsTransportResponse{}
+	resp, err := d.newRequest().
+		SetBody(req).
+		SetResult(res).
+		Post(url)
+
+	if err != nil {
+		d.logger.Errorf("EstablishDataChannelsTransport error: %v", err)
+		return nil, err
+	}
+
+	d.logger.Tracef("EstablishDataChannelsTransport response: %d, %s", resp.StatusCode(), string(resp.Body()))
+
+	if resp.StatusCode() != http.StatusOK && resp.StatusCode() != http.StatusCreated {
+		errRes := baseErrorx.NewErrorXFromResp(resp)
+		d.logger.Errorf("EstablishDataChannelsTransport failed: %v", errRes)
+		return nil, errRes
+	}
+
+	err = json.Unmarshal(resp.Body(), &res)
+	return res, err
+}
+
+func (d defaultSfuApi) NewDataChannels(sessionId string, req *dto.DataChannelsRequest) (*dto.DataChannelsResponse, error) {
+	url := fmt.Sprintf("%s/apps/%s/sessions/%s/datachannels/new", d.endpoint, d.appId, sessionId)
+
+	res := &dto.DataChannelsResponse{}
+	resp, err := d.newRequest().
+		SetBody(req).
+		SetResult(res).
+		Post(url)
+
+	if err != nil {
+		d.logger.Errorf("NewDataChannels error: %v", err)
+		return nil, err
+	}
+
+	d.logger.Tracef("NewDataChannels response: %d, %s", resp.StatusCode(), string(resp.Body()))
+
+	if resp.StatusCode() != http.StatusOK && resp.StatusCode() != http.StatusCreated {
+		errRes := baseErrorx.NewErrorXFromResp(resp)
+		d.logger.Errorf("NewDataChannels failed: %v", errRes)
+		return nil, errRes
+	}
+
+	err = json.Unmarshal(resp.Body(), &res)
+	return res, err
+}
+
+func (d defaultSfuApi) CloseDataChannels(sessionId string, req *dto.CloseDataChannelsRequest) (*dto.CloseDataChannelsResponse, error) {
+	url := fmt.Sprintf("%s/apps/%s/sessions/%s/datachannels/close", d.endpoint, d.appId, sessionId)
+
+	res := &dto.CloseDataChannelsResponse{}
+	resp, err := d.newRequest().
+		SetBody(req).
+		SetResult(res).
+		Put(url)
+
+	if err != nil {
+		d.logger.Errorf("CloseDataChannels error: %v", err)
+		return nil, err
+	}
+
+	d.logger.Tracef("CloseDataChannels response: %d, %s", resp.StatusCode(), string(resp.Body()))
+
+	if resp.StatusCode() != http.StatusOK && resp.StatusCode() != http.StatusCreated {
+		errRes := baseErrorx.NewErrorXFromResp(resp)
+		d.logger.Errorf("CloseDataChannels failed: %v", errRes)
+		return nil, errRes
+	}
+
+	err = json.Unmarshal(resp.Body(), &res)
+	return res, err
+}
+
+func (d defaultSfuApi) NewAdapter(req *dto.NewAdapterRequest) (*dto.NewAdapterResponse, error) {
+	url := fmt.Sprintf("%s/apps/%s/adapters/websocket/new", d.endpoint, d.appId)
+
+	res := &dto.NewAdapterResponse{}
+	resp, err := d.newRequest().
+		SetBody(req).
+		SetResult(res).
+		Post(url)
+
+	if err != nil {
+		d.logger.Errorf("NewAdapter error: %v", err)
+		return nil, err
+	}
+
+	d.logger.Tracef("NewAdapter response: %d, %s", resp.StatusCode(), string(resp.Body()))
+
+	if resp.StatusCode() != http.StatusOK && resp.StatusCode() != http.StatusCreated {
+		errRes := baseErrorx.NewErrorXFromResp(resp)
+		d.logger.Errorf("NewAdapter failed: %v", errRes)
+		return nil, errRes
+	}
+
+	err = json.Unmarshal(resp.Body(), &res)
+	return res, err
+}
+
+func (d defaultSfuApi) CloseAdapter(req *dto.CloseAdapterRequest) (*dto.CloseAdapterResponse, error) {
+	url := fmt.Sprintf("%s/apps/%s/adapters/websocket/close", d.endpoint, d.appId)
+
+	res := &dto.CloseAdapterResponse{}
+	resp, err := d.newRequest().
+		SetBody(req).
+		SetResult(res).
+		Post(url)
+
+	if err != nil {
+		d.logger.Errorf("CloseAdapter error: %v", err)
+		return nil, err
+	}
+
+	d.logger.Tracef("CloseAdapter response: %d, %s", resp.StatusCode(), string(resp.Body()))
+
+	if resp.StatusCode() != http.StatusOK && resp.StatusCode() != http.StatusCreated {
+		errRes := baseErrorx.NewErrorXFromResp(resp)
+		d.logger.Errorf("CloseAdapter failed: %v", errRes)
+		return nil, errRes
+	}
+
+	err = json.Unmarshal(resp.Body(), &res)
+	return res, err
+}
+
 func (d defaultSfuApi) newRequest() *resty.Request {
 	return d.client.R().
 		SetHeader("Content-Type", "application/json").
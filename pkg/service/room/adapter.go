@@ -0,0 +1,67 @@
+package room
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+)
+
+// RoomAdaptersKey 房间内的WebSocket适配器, field为adapterId
+const RoomAdaptersKey = "live_server:room:%s:adapters"
+
+func (r roomService) SaveRoomAdapter(id string, adapter *dto.RoomAdapter) error {
+	b, err := json.Marshal(adapter)
+	if err != nil {
+		return err
+	}
+	return r.appCtx.RoomCache().HSet(r.getRoomAdaptersCacheKey(id), adapter.AdapterId, string(b), 0)
+}
+
+func (r roomService) FindRoomAdapters(id string) ([]*dto.RoomAdapter, error) {
+	values, err := r.appCtx.RoomCache().HValues(r.getRoomAdaptersCacheKey(id))
+	if err != nil {
+		return nil, err
+	}
+	adapters := make([]*dto.RoomAdapter, 0, len(values))
+	for _, v := range values {
+		adapter := &dto.RoomAdapter{}
+		if errJson := json.Unmarshal([]byte(v), adapter); errJson == nil {
+			adapters = append(adapters, adapter)
+		}
+	}
+	return adapters, nil
+}
+
+func (r roomService) DeleteRoomAdapters(id string, adapterIds []string) error {
+	cacheKey := r.getRoomAdaptersCacheKey(id)
+	for _, adapterId := range adapterIds {
+		if err := r.appCtx.RoomCache().HDel(cacheKey, adapterId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeRoomAdapters 房间销毁时关闭房间内剩余的适配器
+func (r roomService) closeRoomAdapters(room *dto.Room) {
+	closer, ok := r.engines[room.Engine].(AdapterCloser)
+	if !ok {
+		return
+	}
+	adapters, err := r.FindRoomAdapters(room.Id)
+	if err != nil || len(adapters) == 0 {
+		return
+	}
+	adapterIds := make([]string, 0, len(adapters))
+	for _, adapter := range adapters {
+		adapterIds = append(adapterIds, adapter.AdapterId)
+	}
+	if errClose := closer.CloseAdapters(adapterIds); errClose != nil {
+		r.appCtx.Logger().Error("closeRoomAdapters", room.Id, errClose)
+	}
+}
+
+func (r roomService) getRoomAdaptersCacheKey(roomId string) string {
+	return fmt.Sprintf(RoomAdaptersKey, roomId)
+}
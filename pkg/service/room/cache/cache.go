@@ -1,14 +1,34 @@
 package cache
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+const (
+	ClusterLocal = "local" // 单节点模式, 数据保存在进程内
+	ClusterRedis = "redis" // 集群模式, 数据保存在redis
+)
+
+// hUpdateMaxRetries HUpdate写入时field已被修改的最大重试次数
+const hUpdateMaxRetries = 10
+
+var errHUpdateConflict = errors.New("hash field changed by other client")
 
 type OnNewMessage func(msg string)
 
+// RoomCache 房间数据存储, key不存在时Get/HGet返回nil值而不是错误
 type RoomCache interface {
 	SetEx(key string, value interface{}, expire time.Duration) error
+	SetNX(key string, value interface{}, expire time.Duration) (bool, error)
 	Expire(key string, expire time.Duration) error
+	// ExpireIfEqual key的值等于value时重新设置过期时间, 返回是否设置成功, 用于续期自己持有的租约
+	ExpireIfEqual(key, value string, expire time.Duration) (bool, error)
+	TTL(key string) (time.Duration, error)
 	Get(key string) (value interface{}, err error)
 	HSet(key, field string, value interface{}, expire time.Duration) error
+	// HUpdate 原子地读取并修改hash字段, value不存在时为空串, update返回false时不写入; 并发冲突重试时update会被多次调用
+	HUpdate(key, field string, update func(value string) (string, bool, error)) error
 	HDel(key, field string) error
 	HGet(key, field string) (interface{}, error)
 	HValues(key string) ([]string, error)
@@ -19,5 +39,11 @@ type RoomCache interface {
 	SAdd(key string, members ...string) (int64, error)
 	SMembers(key string) ([]string, error)
 	SRem(key string, members ...string) (int64, error)
-	Del(key string) error
+	ZAdd(key string, score float64, members ...string) (int64, error)
+	ZScore(key, member string) (float64, bool, error)
+	ZRangeByScore(key string, min, max float64, count int64) ([]string, error)
+	ZRem(key string, members ...string) (int64, error)
+	// ZRemIfScoreBelow member的score不大于max时删除, 返回是否删除, 用于超时检查时避免删除刚更新的member
+	ZRemIfScoreBelow(key, member string, max float64) (bool, error)
+	Del(keys ...string) error
 }
@@ -4,10 +4,14 @@ import (
 	"errors"
 	"github.com/sirupsen/logrus"
 	"github.com/zoumo/goset"
+	"sort"
 	"sync"
 	"time"
 )
 
+// zset 有序集合, member -> score
+type zset map[string]float64
+
 type LocalCache struct {
 	logger    *logrus.Entry
 	data      map[string]interface{}
@@ -15,47 +19,101 @@ type LocalCache struct {
 	rwMutex   *sync.RWMutex
 }
 
+// expired 检查key是否过期, 过期时删除, 调用方需持有写锁
+func (l *LocalCache) expired(key string) bool {
+	expireTime := l.keyExpire[key]
+	if expireTime == nil || expireTime.After(time.Now()) {
+		return false
+	}
+	delete(l.data, key)
+	delete(l.keyExpire, key)
+	return true
+}
+
+func (l *LocalCache) setExpire(key string, expire time.Duration) {
+	if expire <= 0 {
+		return
+	}
+	expireTime := time.Now().Add(expire)
+	l.keyExpire[key] = &expireTime
+}
+
 func (l *LocalCache) SetEx(key string, value interface{}, expire time.Duration) error {
 	l.rwMutex.Lock()
 	defer l.rwMutex.Unlock()
 	l.data[key] = value
-	expireTime := time.Now().Add(expire)
-	l.keyExpire[key] = &expireTime
+	delete(l.keyExpire, key)
+	l.setExpire(key, expire)
 	return nil
 }
 
+func (l *LocalCache) SetNX(key string, value interface{}, expire time.Duration) (bool, error) {
+	l.rwMutex.Lock()
+	defer l.rwMutex.Unlock()
+	if !l.expired(key) && l.data[key] != nil {
+		return false, nil
+	}
+	l.data[key] = value
+	delete(l.keyExpire, key)
+	l.setExpire(key, expire)
+	return true, nil
+}
+
 func (l *LocalCache) Expire(key string, expire time.Duration) error {
 	l.rwMutex.Lock()
 	defer l.rwMutex.Unlock()
+	if l.expired(key) || l.data[key] == nil {
+		return nil
+	}
 	expireTime := time.Now().Add(expire)
 	l.keyExpire[key] = &expireTime
 	return nil
 }
 
-func (l *LocalCache) Get(key string) (value interface{}, err error) {
-	l.rwMutex.RLock()
-	defer l.rwMutex.RUnlock()
+func (l *LocalCache) ExpireIfEqual(key, value string, expire time.Duration) (bool, error) {
+	l.rwMutex.Lock()
+	defer l.rwMutex.Unlock()
+	if l.expired(key) || l.data[key] != value {
+		return false, nil
+	}
+	expireTime := time.Now().Add(expire)
+	l.keyExpire[key] = &expireTime
+	return true, nil
+}
+
+// TTL 与redis一致, key不存在返回-2, 没有过期时间返回-1
+func (l *LocalCache) TTL(key string) (time.Duration, error) {
+	l.rwMutex.Lock()
+	defer l.rwMutex.Unlock()
+	if l.expired(key) || l.data[key] == nil {
+		return -2, nil
+	}
 	expireTime := l.keyExpire[key]
 	if expireTime == nil {
-		return l.data[key], nil
-	} else if expireTime.After(time.Now()) {
-		return l.data[key], nil
-	} else {
-		delete(l.data, key)
+		return -1, nil
+	}
+	return time.Until(*expireTime), nil
+}
+
+func (l *LocalCache) Get(key string) (value interface{}, err error) {
+	l.rwMutex.Lock()
+	defer l.rwMutex.Unlock()
+	if l.expired(key) {
 		return nil, nil
 	}
+	return l.data[key], nil
 }
 
 func (l *LocalCache) HSet(key, field string, value interface{}, expire time.Duration) error {
 	l.rwMutex.Lock()
 	defer l.rwMutex.Unlock()
+	l.expired(key)
 	data := l.data[key]
 	if data == nil {
 		dataMap := make(map[string]interface{})
 		dataMap[field] = value
 		l.data[key] = dataMap
-		expireTime := time.Now().Add(expire)
-		l.keyExpire[key] = &expireTime
+		l.setExpire(key, expire)
 		return nil
 	}
 	dataMap, ok := data.(map[string]interface{})
@@ -63,18 +121,72 @@ func (l *LocalCache) HSet(key, field string, value interface{}, expire time.Dura
 		return errors.New("type err")
 	} else {
 		dataMap[field] = value
-		expireTime := time.Now().Add(expire)
-		l.keyExpire[key] = &expireTime
+		l.setExpire(key, expire)
 		return nil
 	}
 }
 
+// HUpdate 与redis实现一致, update在锁外调用, 写入时field已被修改则重试, update中可以继续访问缓存
+func (l *LocalCache) HUpdate(key, field string, update func(value string) (string, bool, error)) error {
+	for i := 0; i < hUpdateMaxRetries; i++ {
+		value, err := l.hGetString(key, field)
+		if err != nil {
+			return err
+		}
+		newValue, changed, errUpdate := update(value)
+		if errUpdate != nil || !changed {
+			return errUpdate
+		}
+		set, errSet := l.hCompareAndSet(key, field, value, newValue)
+		if errSet != nil || set {
+			return errSet
+		}
+	}
+	return errHUpdateConflict
+}
+
+// hGetString 读取hash字段的字符串值, 不存在时为空串
+func (l *LocalCache) hGetString(key, field string) (string, error) {
+	l.rwMutex.Lock()
+	defer l.rwMutex.Unlock()
+	if l.expired(key) || l.data[key] == nil {
+		return "", nil
+	}
+	dataMap, ok := l.data[key].(map[string]interface{})
+	if !ok {
+		return "", errors.New("type err")
+	}
+	value, _ := dataMap[field].(string)
+	return value, nil
+}
+
+// hCompareAndSet field的值仍为oldValue时写入newValue, 返回是否写入
+func (l *LocalCache) hCompareAndSet(key, field, oldValue, newValue string) (bool, error) {
+	l.rwMutex.Lock()
+	defer l.rwMutex.Unlock()
+	l.expired(key)
+	dataMap := make(map[string]interface{})
+	if data := l.data[key]; data != nil {
+		existed, ok := data.(map[string]interface{})
+		if !ok {
+			return false, errors.New("type err")
+		}
+		dataMap = existed
+	}
+	value, _ := dataMap[field].(string)
+	if value != oldValue {
+		return false, nil
+	}
+	dataMap[field] = newValue
+	l.data[key] = dataMap
+	return true, nil
+}
+
 func (l *LocalCache) HGet(key, field string) (interface{}, error) {
 	l.rwMutex.Lock()
 	defer l.rwMutex.Unlock()
 	data := l.data[key]
-	expireTime := l.keyExpire[key]
-	if expireTime == nil || expireTime.After(time.Now()) {
+	if !l.expired(key) {
 		if data == nil {
 			return nil, nil
 		} else {
@@ -86,7 +198,6 @@ func (l *LocalCache) HGet(key, field string) (interface{}, error) {
 			}
 		}
 	} else {
-		delete(l.data, key)
 		return nil, nil
 	}
 }
@@ -109,11 +220,10 @@ func (l *LocalCache) HDel(key, field string) error {
 }
 
 func (l *LocalCache) HValues(key string) ([]string, error) {
-	l.rwMutex.RLock()
-	defer l.rwMutex.RUnlock()
+	l.rwMutex.Lock()
+	defer l.rwMutex.Unlock()
 	data := l.data[key]
-	expireTime := l.keyExpire[key]
-	if expireTime == nil || expireTime.After(time.Now()) {
+	if !l.expired(key) {
 		if data == nil {
 			return nil, nil
 		} else {
@@ -130,17 +240,15 @@ func (l *LocalCache) HValues(key string) ([]string, error) {
 			}
 		}
 	} else {
-		delete(l.data, key)
 		return nil, nil
 	}
 }
 
 func (l *LocalCache) HFields(key string) ([]string, error) {
-	l.rwMutex.RLock()
-	defer l.rwMutex.RUnlock()
+	l.rwMutex.Lock()
+	defer l.rwMutex.Unlock()
 	data := l.data[key]
-	expireTime := l.keyExpire[key]
-	if expireTime == nil || expireTime.After(time.Now()) {
+	if !l.expired(key) {
 		if data == nil {
 			return nil, nil
 		} else {
@@ -156,7 +264,6 @@ func (l *LocalCache) HFields(key string) ([]string, error) {
 			}
 		}
 	} else {
-		delete(l.data, key)
 		return nil, nil
 	}
 }
@@ -251,7 +358,7 @@ func (l *LocalCache) SRem(key string, members ...string) (int64, error) {
 	l.rwMutex.Lock()
 	defer l.rwMutex.Unlock()
 	if l.data[key] == nil {
-		return 0, errors.New("key error")
+		return 0, nil
 	} else {
 		if sets, ok := l.data[key].(goset.Set); ok {
 			c := 0
@@ -268,10 +375,114 @@ func (l *LocalCache) SRem(key string, members ...string) (int64, error) {
 	}
 }
 
-func (l *LocalCache) Del(key string) error {
+func (l *LocalCache) ZAdd(key string, score float64, members ...string) (int64, error) {
 	l.rwMutex.Lock()
 	defer l.rwMutex.Unlock()
-	delete(l.data, key)
+	l.expired(key)
+	if l.data[key] == nil {
+		l.data[key] = make(zset)
+	}
+	set, ok := l.data[key].(zset)
+	if !ok {
+		return 0, errors.New("key error")
+	}
+	added := 0
+	for _, m := range members {
+		if _, existed := set[m]; !existed {
+			added++
+		}
+		set[m] = score
+	}
+	return int64(added), nil
+}
+
+func (l *LocalCache) ZScore(key, member string) (float64, bool, error) {
+	l.rwMutex.Lock()
+	defer l.rwMutex.Unlock()
+	if l.expired(key) || l.data[key] == nil {
+		return 0, false, nil
+	}
+	set, ok := l.data[key].(zset)
+	if !ok {
+		return 0, false, errors.New("key error")
+	}
+	score, existed := set[member]
+	return score, existed, nil
+}
+
+func (l *LocalCache) ZRangeByScore(key string, min, max float64, count int64) ([]string, error) {
+	l.rwMutex.Lock()
+	defer l.rwMutex.Unlock()
+	if l.expired(key) || l.data[key] == nil {
+		return nil, nil
+	}
+	set, ok := l.data[key].(zset)
+	if !ok {
+		return nil, errors.New("key error")
+	}
+	members := make([]string, 0)
+	for m, score := range set {
+		if score >= min && score <= max {
+			members = append(members, m)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if set[members[i]] == set[members[j]] {
+			return members[i] < members[j]
+		}
+		return set[members[i]] < set[members[j]]
+	})
+	if count > 0 && int64(len(members)) > count {
+		members = members[:count]
+	}
+	return members, nil
+}
+
+func (l *LocalCache) ZRem(key string, members ...string) (int64, error) {
+	l.rwMutex.Lock()
+	defer l.rwMutex.Unlock()
+	if l.expired(key) || l.data[key] == nil {
+		return 0, nil
+	}
+	set, ok := l.data[key].(zset)
+	if !ok {
+		return 0, errors.New("key error")
+	}
+	removed := 0
+	for _, m := range members {
+		if _, existed := set[m]; existed {
+			removed++
+			delete(set, m)
+		}
+	}
+	return int64(removed), nil
+}
+
+func (l *LocalCache) ZRemIfScoreBelow(key, member string, max float64) (bool, error) {
+	l.rwMutex.Lock()
+	defer l.rwMutex.Unlock()
+	if l.expired(key) || l.data[key] == nil {
+		return false, nil
+	}
+	set, ok := l.data[key].(zset)
+	if !ok {
+		return false, errors.New("key error")
+	}
+	score, existed := set[member]
+	if !existed || score > max {
+		return false, nil
+	}
+	delete(set, member)
+	return true, nil
+}
+
+func (l *LocalCache) Del(keys ...string) error {
+	l.rwMutex.Lock()
+	defer l.rwMutex.Unlock()
+	for _, key := range keys {
+		delete(l.data, key)
+		delete(l.keyExpire, key)
+	}
 	return nil
 }
 
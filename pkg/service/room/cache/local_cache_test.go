@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestLocalCache() RoomCache {
+	return MakeLocalCache(logrus.NewEntry(logrus.New()))
+}
+
+func TestLocalCacheHUpdate(t *testing.T) {
+	c := newTestLocalCache()
+	if err := c.HUpdate("key", "field", func(value string) (string, bool, error) {
+		if value != "" {
+			t.Fatalf("missing field value %q", value)
+		}
+		return "1", true, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.HUpdate("key", "field", func(value string) (string, bool, error) {
+		return "2", false, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	errUpdate := errors.New("update")
+	if err := c.HUpdate("key", "field", func(value string) (string, bool, error) {
+		return "3", true, errUpdate
+	}); !errors.Is(err, errUpdate) {
+		t.Fatalf("unexpected err %v", err)
+	}
+	if value, _ := c.HGet("key", "field"); value != "1" {
+		t.Fatalf("unexpected value %v", value)
+	}
+}
+
+// update中访问缓存不能死锁, 其他field的修改不影响写入
+func TestLocalCacheHUpdateReentrant(t *testing.T) {
+	c := newTestLocalCache()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.HUpdate("key", "a", func(value string) (string, bool, error) {
+			if err := c.HSet("key", "b", "other", 0); err != nil {
+				return "", false, err
+			}
+			return "a", true, nil
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("HUpdate deadlock")
+	}
+	if value, _ := c.HGet("key", "a"); value != "a" {
+		t.Fatalf("unexpected value %v", value)
+	}
+}
+
+// field在update期间被修改时重试, update读到最新的值
+func TestLocalCacheHUpdateConflict(t *testing.T) {
+	c := newTestLocalCache()
+	calls := 0
+	err := c.HUpdate("key", "field", func(value string) (string, bool, error) {
+		calls++
+		if calls == 1 {
+			if errSet := c.HSet("key", "field", "changed", 0); errSet != nil {
+				return "", false, errSet
+			}
+		}
+		return value + "+1", true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("update called %d times", calls)
+	}
+	if value, _ := c.HGet("key", "field"); value != "changed+1" {
+		t.Fatalf("unexpected value %v", value)
+	}
+}
+
+// 检查与删除之间重新上报了心跳时不删除
+func TestLocalCacheZRemIfScoreBelow(t *testing.T) {
+	c := newTestLocalCache()
+	if _, err := c.ZAdd("key", 10, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ZAdd("key", 30, "b"); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		member string
+		want   bool
+	}{
+		{"a", true},
+		{"a", false},
+		{"b", false},
+		{"missing", false},
+	}
+	for _, tc := range cases {
+		if removed, err := c.ZRemIfScoreBelow("key", tc.member, 20); err != nil || removed != tc.want {
+			t.Errorf("member %s removed %v, want %v, err %v", tc.member, removed, tc.want, err)
+		}
+	}
+	if _, existed, _ := c.ZScore("key", "b"); !existed {
+		t.Fatal("fresh member removed")
+	}
+}
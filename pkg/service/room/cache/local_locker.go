@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"github.com/thk-im/thk-im-base-server/locker"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type localLock struct {
+	token    string
+	expireAt time.Time
+}
+
+// LocalLockers 进程内锁, 单节点模式下替代redis分布式锁
+type LocalLockers struct {
+	mutex *sync.Mutex
+	locks map[string]*localLock
+	seq   int64
+}
+
+type LocalLocker struct {
+	lockers  *LocalLockers
+	key      string
+	token    string
+	waitTime time.Duration
+	timeout  time.Duration
+}
+
+// Lock 在waitTime内反复尝试加锁, 锁在timeout后自动失效
+func (l *LocalLocker) Lock() (bool, error) {
+	deadline := time.Now().Add(l.waitTime)
+	for {
+		if l.lockers.tryLock(l.key, l.token, l.timeout) {
+			return true, nil
+		}
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (l *LocalLocker) Release() (bool, error) {
+	return l.lockers.release(l.key, l.token), nil
+}
+
+func (l *LocalLockers) tryLock(key, token string, timeout time.Duration) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	lock := l.locks[key]
+	if lock != nil && lock.expireAt.After(now) {
+		return false
+	}
+	l.locks[key] = &localLock{token: token, expireAt: now.Add(timeout)}
+	return true
+}
+
+func (l *LocalLockers) release(key, token string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	lock := l.locks[key]
+	if lock == nil || lock.token != token {
+		return false
+	}
+	delete(l.locks, key)
+	return true
+}
+
+// NewLocker waitTime和timeout单位ms, 与appCtx.NewLocker一致
+func (l *LocalLockers) NewLocker(key string, waitTime, timeout int) locker.Locker {
+	l.mutex.Lock()
+	l.seq++
+	token := strconv.FormatInt(l.seq, 10)
+	l.mutex.Unlock()
+	return &LocalLocker{
+		lockers:  l,
+		key:      key,
+		token:    token,
+		waitTime: time.Duration(waitTime) * time.Millisecond,
+		timeout:  time.Duration(timeout) * time.Millisecond,
+	}
+}
+
+func MakeLocalLockers() *LocalLockers {
+	return &LocalLockers{
+		mutex: &sync.Mutex{},
+		locks: make(map[string]*localLock),
+	}
+}
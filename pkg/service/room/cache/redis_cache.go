@@ -2,14 +2,47 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
+	"math"
+	"strconv"
 	"sync"
 	"time"
 )
 
+// hCompareAndSetScript field的值未被修改时写入新值, 返回1表示写入成功
+var hCompareAndSetScript = redis.NewScript(`
+local value = redis.call('HGET', KEYS[1], ARGV[1])
+if value == false then
+	value = ''
+end
+if value ~= ARGV[2] then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+return 1
+`)
+
+// zRemIfScoreBelowScript member的score不大于ARGV[2]时删除, 返回删除的数量
+var zRemIfScoreBelowScript = redis.NewScript(`
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if score and tonumber(score) <= tonumber(ARGV[2]) then
+	return redis.call('ZREM', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// expireIfEqualScript key的值等于ARGV[1]时设置过期时间ARGV[2](ms)
+var expireIfEqualScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
 type RedisCache struct {
-	client    *redis.Client
+	client    redis.UniversalClient
 	logger    *logrus.Entry
 	rwMutex   *sync.RWMutex
 	pubSubMap map[string]*redis.PubSub
@@ -20,15 +53,34 @@ func (r *RedisCache) Expire(key string, expire time.Duration) error {
 	return r.client.Expire(ctx, key, expire).Err()
 }
 
+func (r *RedisCache) ExpireIfEqual(key, value string, expire time.Duration) (bool, error) {
+	ctx := context.Background()
+	set, err := expireIfEqualScript.Run(ctx, r.client, []string{key}, value, expire.Milliseconds()).Int()
+	return set == 1, err
+}
+
+func (r *RedisCache) TTL(key string) (time.Duration, error) {
+	ctx := context.Background()
+	return r.client.TTL(ctx, key).Result()
+}
+
 func (r *RedisCache) SetEx(key string, value interface{}, expire time.Duration) error {
 	ctx := context.Background()
 	return r.client.SetEx(ctx, key, value, expire).Err()
 }
 
+func (r *RedisCache) SetNX(key string, value interface{}, expire time.Duration) (bool, error) {
+	ctx := context.Background()
+	return r.client.SetNX(ctx, key, value, expire).Result()
+}
+
 func (r *RedisCache) Get(key string) (value interface{}, err error) {
 	ctx := context.Background()
-	cmd := r.client.Get(ctx, key)
-	return cmd.Result()
+	value, err = r.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return value, err
 }
 
 func (r *RedisCache) HSet(key, field string, value interface{}, expire time.Duration) error {
@@ -36,10 +88,36 @@ func (r *RedisCache) HSet(key, field string, value interface{}, expire time.Dura
 	return r.client.HSet(ctx, key, field, value).Err()
 }
 
+// HUpdate 读取field后通过lua脚本比较并写入, 只有同一field被其他客户端修改时才重试
+func (r *RedisCache) HUpdate(key, field string, update func(value string) (string, bool, error)) error {
+	ctx := context.Background()
+	for i := 0; i < hUpdateMaxRetries; i++ {
+		value, err := r.client.HGet(ctx, key, field).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		newValue, changed, errUpdate := update(value)
+		if errUpdate != nil || !changed {
+			return errUpdate
+		}
+		set, errSet := hCompareAndSetScript.Run(ctx, r.client, []string{key}, field, value, newValue).Int()
+		if errSet != nil {
+			return errSet
+		}
+		if set == 1 {
+			return nil
+		}
+	}
+	return errHUpdateConflict
+}
+
 func (r *RedisCache) HGet(key, field string) (interface{}, error) {
 	ctx := context.Background()
-	cmd := r.client.HGet(ctx, key, field)
-	return cmd.Result()
+	value, err := r.client.HGet(ctx, key, field).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return value, err
 }
 
 func (r *RedisCache) HDel(key, field string) error {
@@ -115,12 +193,60 @@ func (r *RedisCache) SRem(key string, members ...string) (int64, error) {
 	return r.client.SRem(ctx, key, members).Result()
 }
 
-func (r *RedisCache) Del(key string) error {
+func (r *RedisCache) ZAdd(key string, score float64, members ...string) (int64, error) {
+	ctx := context.Background()
+	zs := make([]redis.Z, 0, len(members))
+	for _, m := range members {
+		zs = append(zs, redis.Z{Score: score, Member: m})
+	}
+	return r.client.ZAdd(ctx, key, zs...).Result()
+}
+
+func (r *RedisCache) ZScore(key, member string) (float64, bool, error) {
+	ctx := context.Background()
+	score, err := r.client.ZScore(ctx, key, member).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, false, nil
+	}
+	return score, err == nil, err
+}
+
+func (r *RedisCache) ZRangeByScore(key string, min, max float64, count int64) ([]string, error) {
+	ctx := context.Background()
+	return r.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+		Min:   formatScore(min),
+		Max:   formatScore(max),
+		Count: count,
+	}).Result()
+}
+
+func (r *RedisCache) ZRem(key string, members ...string) (int64, error) {
+	ctx := context.Background()
+	return r.client.ZRem(ctx, key, members).Result()
+}
+
+func (r *RedisCache) ZRemIfScoreBelow(key, member string, max float64) (bool, error) {
 	ctx := context.Background()
-	return r.client.Del(ctx, key).Err()
+	removed, err := zRemIfScoreBelowScript.Run(ctx, r.client, []string{key}, member, formatScore(max)).Int()
+	return removed > 0, err
+}
+
+func (r *RedisCache) Del(keys ...string) error {
+	ctx := context.Background()
+	return r.client.Del(ctx, keys...).Err()
+}
+
+func formatScore(score float64) string {
+	if math.IsInf(score, 1) {
+		return "+inf"
+	}
+	if math.IsInf(score, -1) {
+		return "-inf"
+	}
+	return strconv.FormatFloat(score, 'f', -1, 64)
 }
 
-func MakeRedisCache(client *redis.Client, logger *logrus.Entry) RoomCache {
+func MakeRedisCache(client redis.UniversalClient, logger *logrus.Entry) RoomCache {
 	return &RedisCache{
 		client:    client,
 		logger:    logger.WithField("search_index", "redis_cache"),
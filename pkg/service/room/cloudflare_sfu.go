@@ -1,171 +1,433 @@
 package room
 
 import (
-	"context"
-	"errors"
-	"fmt"
 	"time"
 
-	"github.com/redis/go-redis/v9"
 	baseDto "github.com/thk-im/thk-im-base-server/dto"
-	baseErrorx "github.com/thk-im/thk-im-base-server/errorx"
+	baseErr "github.com/thk-im/thk-im-base-server/errorx"
 	"github.com/thk-im/thk-im-livecall-server/pkg/app"
 	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
-	"github.com/thk-im/thk-im-livecall-server/pkg/errorx"
-	"github.com/thk-im/thk-im-livecall-server/pkg/service/signal"
+	"github.com/thk-im/thk-im-livecall-server/pkg/sdk"
 )
 
-const RoomsKey = "live_server:rooms:"
+// cloudflareSFUEngine Cloudflare Calls引擎, 参与人推流会话id保存在StreamKey
+type cloudflareSFUEngine struct {
+	appCtx  *app.Context
+	service Service
+}
+
+func newCloudflareSFUEngine(appCtx *app.Context, service Service) Engine {
+	return &cloudflareSFUEngine{appCtx: appCtx, service: service}
+}
+
+func (e cloudflareSFUEngine) api() sdk.SfuApi {
+	return e.appCtx.CloudflareConnectApi()
+}
+
+func (e cloudflareSFUEngine) Name() string {
+	return dto.EngineCloudflareSFU
+}
 
-func NewCloudflareSFURoomService(appCtx *app.Context) Service {
-	return &CloudflareSFURoomService{
-		baseRoomService: baseRoomService{
-			appCtx:        appCtx,
-			signalService: signal.NewSignalService(appCtx),
+// IsParticipantAlive 会话中还有活跃的track或数据通道
+func (e cloudflareSFUEngine) IsParticipantAlive(participant *dto.Participant) (bool, error) {
+	if participant.StreamKey == "" {
+		return false, nil
+	}
+	resp, err := e.api().GetSessionState(participant.StreamKey)
+	if err != nil {
+		return false, err
+	}
+	if resp.ErrorCode != "" {
+		return false, nil
+	}
+	for _, t := range resp.Tracks {
+		if t.Status != "inactive" {
+			return true, nil
+		}
+	}
+	return len(resp.DataChannels) > 0, nil
+}
+
+func (e cloudflareSFUEngine) PublishStream(room *dto.Room, req *dto.PublishStreamReq, claims baseDto.ThkClaims) (*dto.PublishStreamResp, error) {
+	resp, err := e.api().CreateSession()
+	if err != nil {
+		e.appCtx.Logger().Error("PublishStream err, ", err)
+		return nil, baseErr.ErrInternalServerError
+	}
+
+	if resp == nil || resp.SessionID == "" {
+		e.appCtx.Logger().Error("PublishStream resp err", resp)
+		return nil, baseErr.ErrInternalServerError
+	}
+
+	// 房间媒体参数未开启的音视频m-line改为inactive, 不推到sfu
+	offer := dto.DisableSdpMedia(req.Sdp, room.MediaParams)
+	tracks := make([]dto.TrackObject, 0)
+	for _, t := range dto.NameTracks(dto.ParseTracksFromSDP(offer, resp.SessionID), req.Tracks) {
+		if t.Kind == dto.TrackKindVideo && !room.MediaParams.VideoEnable() || t.Kind == dto.TrackKindAudio && !room.MediaParams.AudioEnable() {
+			continue
+		}
+		tracks = append(tracks, t)
+	}
+
+	tracksResp, errTracks := e.api().NewTracks(resp.SessionID, &dto.TracksRequest{
+		SessionDescription: &dto.SessionDescription{
+			Type: "offer",
+			SDP:  offer,
 		},
+		Tracks: tracks,
+	})
+
+	if errTracks != nil {
+		e.appCtx.Logger().Error("PublishStream err, ", err)
+		return nil, baseErr.ErrInternalServerError
+	}
+
+	if tracksResp == nil || tracksResp.ErrorCode != "" || resp.SessionID == "" || tracksResp.SessionDescription == nil {
+		e.appCtx.Logger().Error("PublishStream resp err", resp)
+		return nil, baseErr.ErrInternalServerError
+	}
+
+	_ = e.service.OnUserJoinEvent(&dto.RoomUserJoinEvent{
+		RoomId:    room.Id,
+		UserId:    req.Uid,
+		Timestamp: time.Now().UnixMilli(),
+	}, claims)
+
+	participantTracks := make([]*dto.ParticipantTrack, 0, len(tracks))
+	for _, t := range tracks {
+		participantTracks = append(participantTracks, &dto.ParticipantTrack{
+			SessionId: resp.SessionID,
+			Mid:       t.Mid,
+			Kind:      t.Kind,
+			TrackName: t.TrackName,
+			Rids:      t.Rids,
+		})
 	}
+	if errSave := e.service.UpdateMemberTracks(room.Id, req.Uid, participantTracks, claims); errSave != nil {
+		e.appCtx.Logger().Error("PublishStream UpdateMemberTracks err, ", errSave)
+	}
+
+	// sfu不限制推流码率, 码率/分辨率/帧率上限写入answer, 由客户端按answer限制发送
+	answer := dto.LimitSdpMedia(tracksResp.SessionDescription.SDP, room.MediaParams)
+	return &dto.PublishStreamResp{SessionId: resp.SessionID, Sdp: answer, Type: tracksResp.SessionDescription.Type}, nil
 }
 
-type CloudflareSFURoomService struct {
-	baseRoomService
+// SubscribeStream 将req.Tracks中的远端track加入拉流会话, 未指定拉流会话时新建
+func (e cloudflareSFUEngine) SubscribeStream(room *dto.Room, req *dto.SubscribeStreamReq, claims baseDto.ThkClaims) (*dto.SubscribeStreamResp, error) {
+	sessionId := req.SubscriberSessionId
+	if sessionId == "" {
+		resp, err := e.api().CreateSession()
+		if err != nil {
+			e.appCtx.Logger().Error("SubscribeStream err, ", err)
+			return nil, baseErr.ErrInternalServerError
+		}
+
+		if resp == nil || resp.SessionID == "" {
+			e.appCtx.Logger().Error("SubscribeStream resp err", resp)
+			return nil, baseErr.ErrInternalServerError
+		}
+		sessionId = resp.SessionID
+	}
+	if len(req.Tracks) == 0 {
+		return &dto.SubscribeStreamResp{SessionId: sessionId, Tracks: req.Tracks}, nil
+	}
+
+	tracksReq := &dto.TracksRequest{Tracks: e.trackObjects(sessionId, req.Tracks)}
+	if req.Sdp != "" {
+		tracksReq.SessionDescription = &dto.SessionDescription{
+			SDP:  req.Sdp,
+			Type: "offer",
+		}
+	}
+	tracksResp, tracksError := e.api().NewTracks(sessionId, tracksReq)
+
+	if tracksError != nil {
+		e.appCtx.Logger().Error("SubscribeStream err, ", tracksError)
+		return nil, baseErr.ErrInternalServerError
+	}
+	if tracksResp == nil || tracksResp.ErrorCode != "" {
+		e.appCtx.Logger().Error("SubscribeStream resp err", tracksResp)
+		return nil, baseErr.ErrInternalServerError
+	}
+
+	streamTracks := e.streamTracks(req.Tracks, tracksResp.Tracks)
+	subscriptions := make([]*dto.ParticipantTrack, 0, len(streamTracks))
+	for _, t := range streamTracks {
+		subscriptions = append(subscriptions, &dto.ParticipantTrack{
+			SessionId:       sessionId,
+			RemoteSessionId: t.RemoteSessionId,
+			Mid:             t.Mid,
+			Kind:            t.Kind,
+			TrackName:       t.TrackName,
+			PreferredRid:    t.PreferredRid,
+		})
+	}
+	if errSave := e.service.AddMemberSubscriptions(room.Id, req.Uid, subscriptions, claims); errSave != nil {
+		e.appCtx.Logger().Error("SubscribeStream AddMemberSubscriptions err, ", errSave)
+	}
+
+	res := &dto.SubscribeStreamResp{
+		SessionId:     sessionId,
+		Renegotiation: tracksResp.RequiresImmediateRenegotiation,
+		Tracks:        streamTracks,
+	}
+	if tracksResp.SessionDescription != nil {
+		res.Sdp = tracksResp.SessionDescription.SDP
+		res.Type = tracksResp.SessionDescription.Type
+	}
+	return res, nil
 }
 
-//func (w CloudflareSFURoomService) api() sdk.SfuApi {
-//	if w.appCtx.Context.SdkMap["cloudflare_connect_api"] == nil {
-//		return nil
-//	}
-//	return w.appCtx.Context.SdkMap["cloudflare_connect_api"].(sdk.SfuApi)
-//}
+func (e cloudflareSFUEngine) Renegotiate(room *dto.Room, req *dto.RenegotiateReq, claims baseDto.ThkClaims) (*dto.RenegotiateResp, error) {
+	sdpType := req.Type
+	if sdpType == "" {
+		sdpType = "answer"
+	}
+	resp, err := e.api().Renegotiate(req.SessionId, &dto.RenegotiateRequest{
+		SessionDescription: &dto.SessionDescription{
+			Type: sdpType,
+			SDP:  req.Sdp,
+		},
+	})
+	if err != nil {
+		e.appCtx.Logger().Error("Renegotiate err, ", err)
+		return nil, baseErr.ErrInternalServerError
+	}
+	if resp == nil || resp.ErrorCode != "" {
+		e.appCtx.Logger().Error("Renegotiate resp err", resp)
+		return nil, baseErr.ErrInternalServerError
+	}
+	res := &dto.RenegotiateResp{}
+	if resp.SessionDescription != nil {
+		res.Sdp = resp.SessionDescription.SDP
+		res.Type = resp.SessionDescription.Type
+	}
+	return res, nil
+}
 
-func (w CloudflareSFURoomService) CreateRoom(req *dto.RoomCreateReq, claims baseDto.ThkClaims) (*dto.RoomJoinResp, error) {
-	lockerKey := fmt.Sprintf(SessionLockerKey, req.SessionId)
-	locker := w.appCtx.NewLocker(lockerKey, 3000, 3000)
-	success, errLock := locker.Lock()
-	if errLock != nil {
-		return nil, errLock
+func (e cloudflareSFUEngine) AddTracks(room *dto.Room, req *dto.StreamTracksReq, claims baseDto.ThkClaims) (*dto.StreamTracksResp, error) {
+	tracksReq := &dto.TracksRequest{Tracks: e.trackObjects(req.SessionId, req.Tracks)}
+	if req.Sdp != "" {
+		tracksReq.SessionDescription = &dto.SessionDescription{
+			Type: "offer",
+			SDP:  req.Sdp,
+		}
+	}
+	tracksResp, err := e.api().NewTracks(req.SessionId, tracksReq)
+	if err != nil {
+		e.appCtx.Logger().Error("AddTracks err, ", err)
+		return nil, baseErr.ErrInternalServerError
+	}
+	if tracksResp == nil || tracksResp.ErrorCode != "" {
+		e.appCtx.Logger().Error("AddTracks resp err", tracksResp)
+		return nil, baseErr.ErrInternalServerError
+	}
+	res := &dto.StreamTracksResp{
+		Renegotiation: tracksResp.RequiresImmediateRenegotiation,
+		Tracks:        e.streamTracks(req.Tracks, tracksResp.Tracks),
 	}
-	if !success {
-		return nil, baseErrorx.ErrInternalServerError
+	if tracksResp.SessionDescription != nil {
+		res.Sdp = tracksResp.SessionDescription.SDP
+		res.Type = tracksResp.SessionDescription.Type
+		if res.Type == "answer" {
+			res.Sdp = dto.LimitSdpMedia(res.Sdp, room.MediaParams)
+		}
 	}
-	defer func() {
-		_, _ = locker.Release()
-	}()
+	return res, nil
+}
 
-	sessionCacheKey := w.getSessionCacheKey(req.SessionId)
-	roomId, errExist := w.appCtx.RedisCache().Get(context.Background(), sessionCacheKey).Result()
-	if errExist != nil && !errors.Is(errExist, redis.Nil) {
-		return nil, errExist
+func (e cloudflareSFUEngine) UpdateTracks(room *dto.Room, req *dto.StreamTracksReq, claims baseDto.ThkClaims) (*dto.StreamTracksResp, error) {
+	updateReq := &dto.UpdateTracksRequest{Tracks: e.trackObjects(req.SessionId, req.Tracks)}
+	if req.Sdp != "" {
+		updateReq.SessionDescription = &dto.SessionDescription{
+			Type: "offer",
+			SDP:  req.Sdp,
+		}
 	}
-	resp := &dto.RoomJoinResp{}
-	if roomId != "" {
-		room, errRoom := w.FindRoomById(roomId, claims)
-		if errRoom != nil && !errors.Is(errRoom, redis.Nil) {
-			return nil, errRoom
+	updateResp, err := e.api().UpdateTracks(req.SessionId, updateReq)
+	if err != nil {
+		e.appCtx.Logger().Error("UpdateTracks err, ", err)
+		return nil, baseErr.ErrInternalServerError
+	}
+	if updateResp == nil || updateResp.ErrorCode != "" {
+		e.appCtx.Logger().Error("UpdateTracks resp err", updateResp)
+		return nil, baseErr.ErrInternalServerError
+	}
+	return &dto.StreamTracksResp{
+		Renegotiation: updateResp.RequiresImmediateRenegotiation,
+		Tracks:        e.streamTracks(req.Tracks, updateResp.Tracks),
+	}, nil
+}
+
+func (e cloudflareSFUEngine) trackObjects(sessionId string, tracks []*dto.StreamTrack) []dto.TrackObject {
+	objects := make([]dto.TrackObject, 0, len(tracks))
+	for _, t := range tracks {
+		object := dto.TrackObject{
+			Location:  "local",
+			Mid:       t.Mid,
+			SessionID: sessionId,
+			TrackName: t.TrackName,
+			Kind:      t.Kind,
 		}
-		if room != nil {
-			for _, p := range room.Participants {
-				if p.UId == req.UId && p.StreamKey != "" {
-					err := w.DestroyRoom(room.Id, claims)
-					if err != nil {
-						return nil, err
-					}
-				}
-			}
-			resp.Room = room
+		if t.RemoteSessionId != "" {
+			object.Location = "remote"
+			object.SessionID = t.RemoteSessionId
 		}
+		if t.PreferredRid != "" {
+			object.Simulcast = &dto.SimulcastConfig{PreferredRid: t.PreferredRid}
+		}
+		objects = append(objects, object)
 	}
+	return objects
+}
 
-	if resp.Room == nil {
-		id := w.appCtx.SnowflakeNode().Generate().Base36()
-		room, errCreateRoom := w.baseRoomService.CreateRoom(id, "WebRTC", req, claims)
-		if errCreateRoom != nil {
-			return nil, errCreateRoom
+// streamTracks 转换sfu返回的track, sfu按请求顺序返回, 缺省字段取请求中的值, 忽略失败的track
+func (e cloudflareSFUEngine) streamTracks(reqTracks []*dto.StreamTrack, tracks []dto.TrackResponse) []*dto.StreamTrack {
+	streamTracks := make([]*dto.StreamTrack, 0, len(tracks))
+	for i, t := range tracks {
+		if t.ErrorCode != "" {
+			e.appCtx.Logger().Error("track err, ", t.Mid, t.TrackName, t.ErrorCode, t.ErrorDescription)
+			continue
+		}
+		track := &dto.StreamTrack{}
+		if i < len(reqTracks) {
+			*track = *reqTracks[i]
+		}
+		if t.Mid != "" {
+			track.Mid = t.Mid
+		}
+		if t.Kind != "" {
+			track.Kind = t.Kind
 		}
-		if room == nil {
-			return nil, baseErrorx.ErrInternalServerError
+		if t.TrackName != "" {
+			track.TrackName = t.TrackName
 		}
-		resp.Room = room
+		if t.Location == "remote" && t.SessionID != "" {
+			track.RemoteSessionId = t.SessionID
+		}
+		if t.Simulcast != nil && t.Simulcast.PreferredRid != "" {
+			track.PreferredRid = t.Simulcast.PreferredRid
+		}
+		streamTracks = append(streamTracks, track)
 	}
-	_, err := w.appCtx.RedisCache().SAdd(context.Background(), RoomsKey, []string{resp.Room.Id}).Result()
+	return streamTracks
+}
+
+func (e cloudflareSFUEngine) EstablishDataChannelTransport(room *dto.Room, req *dto.DataChannelTransportReq, claims baseDto.ThkClaims) (*dto.DataChannelTransportResp, error) {
+	resp, err := e.api().EstablishDataChannelsTransport(req.SessionId, &dto.EstablishDataChannelsTransportRequest{
+		SessionDescription: &dto.SessionDescription{
+			Type: "offer",
+			SDP:  req.Sdp,
+		},
+	})
 	if err != nil {
-		return nil, err
+		e.appCtx.Logger().Error("EstablishDataChannelTransport err, ", err)
+		return nil, baseErr.ErrInternalServerError
+	}
+	if resp == nil || resp.ErrorCode != "" {
+		e.appCtx.Logger().Error("EstablishDataChannelTransport resp err", resp)
+		return nil, baseErr.ErrInternalServerError
 	}
-	err = w.AddRoomMember(resp.Room.Id, req.UId, claims)
-	return resp, err
+	res := &dto.DataChannelTransportResp{
+		Renegotiation: resp.RequiresImmediateRenegotiation,
+	}
+	if resp.SessionDescription != nil {
+		res.Sdp = resp.SessionDescription.SDP
+		res.Type = resp.SessionDescription.Type
+	}
+	return res, nil
+}
+
+func (e cloudflareSFUEngine) PublishDataChannel(room *dto.Room, req *dto.PublishDataChannelReq, claims baseDto.ThkClaims) (*dto.DataChannelResp, error) {
+	return e.newDataChannel(req.SessionId, dto.DataChannelObject{
+		Location:        "local",
+		DataChannelName: req.Name,
+	})
+}
+
+func (e cloudflareSFUEngine) SubscribeDataChannel(room *dto.Room, req *dto.SubscribeDataChannelReq, claims baseDto.ThkClaims) (*dto.DataChannelResp, error) {
+	return e.newDataChannel(req.SessionId, dto.DataChannelObject{
+		Location:        "remote",
+		SessionID:       req.RemoteSessionId,
+		DataChannelName: req.Name,
+	})
+}
+
+func (e cloudflareSFUEngine) CloseDataChannel(room *dto.Room, req *dto.CloseDataChannelReq, claims baseDto.ThkClaims) error {
+	channels := make([]dto.DataChannelObject, 0, len(req.Names))
+	for _, name := range req.Names {
+		channels = append(channels, dto.DataChannelObject{DataChannelName: name})
+	}
+	resp, err := e.api().CloseDataChannels(req.SessionId, &dto.CloseDataChannelsRequest{DataChannels: channels})
+	if err != nil {
+		e.appCtx.Logger().Error("CloseDataChannel err, ", err)
+		return baseErr.ErrInternalServerError
+	}
+	if resp == nil || resp.ErrorCode != "" {
+		e.appCtx.Logger().Error("CloseDataChannel resp err", resp)
+		return baseErr.ErrInternalServerError
+	}
+	return nil
 }
 
-func (w CloudflareSFURoomService) RequestJoinRoom(req *dto.RoomJoinReq, claims baseDto.ThkClaims) (*dto.RoomJoinResp, error) {
-	room, errRoom := w.baseRoomService.FindRoomById(req.RoomId, claims)
-	if errRoom != nil {
-		return nil, errRoom
+func (e cloudflareSFUEngine) newDataChannel(sessionId string, channel dto.DataChannelObject) (*dto.DataChannelResp, error) {
+	resp, err := e.api().NewDataChannels(sessionId, &dto.DataChannelsRequest{
+		DataChannels: []dto.DataChannelObject{channel},
+	})
+	if err != nil {
+		e.appCtx.Logger().Error("NewDataChannels err, ", err)
+		return nil, baseErr.ErrInternalServerError
 	}
-	if room == nil {
-		return nil, errorx.ErrRoomNotExisted
+	if resp == nil || resp.ErrorCode != "" || len(resp.DataChannels) == 0 || resp.DataChannels[0].ErrorCode != "" {
+		e.appCtx.Logger().Error("NewDataChannels resp err", resp)
+		return nil, baseErr.ErrInternalServerError
 	}
-	return &dto.RoomJoinResp{
-		Room:  room,
-		Token: "",
+	return &dto.DataChannelResp{
+		Name: resp.DataChannels[0].DataChannelName,
+		Id:   resp.DataChannels[0].ID,
 	}, nil
 }
 
-func (w CloudflareSFURoomService) CheckRooms() error {
-	claims := baseDto.ThkClaims{}
-	claims.PutValue(baseDto.TraceID, fmt.Sprintf("CheckRooms-%d", time.Now().UnixNano()))
-	claims.PutValue(baseDto.SpanID, "1")
-	for {
-		id, err := w.appCtx.RedisCache().SPop(context.Background(), RoomsKey).Result()
-		if err != nil {
-			if errors.Is(err, redis.Nil) {
-				return nil
-			}
+// CloseTracks 按所在会话分组强制关闭track
+func (e cloudflareSFUEngine) CloseTracks(tracks []*dto.ParticipantTrack) error {
+	sessionTracks := make(map[string][]dto.CloseTrackObject)
+	for _, t := range tracks {
+		if t.SessionId == "" || t.Mid == "" {
+			continue
 		}
-		err = w.checkRoom(id, claims)
+		sessionTracks[t.SessionId] = append(sessionTracks[t.SessionId], dto.CloseTrackObject{Mid: t.Mid})
+	}
+	for sessionId, closeTracks := range sessionTracks {
+		resp, err := e.api().CloseTracks(sessionId, &dto.CloseTracksRequest{
+			Tracks: closeTracks,
+			Force:  true,
+		})
 		if err != nil {
-			return err
-		}
-		time.Sleep(5 * time.Second)
-	}
-}
-
-func (w CloudflareSFURoomService) checkRoom(id string, claims baseDto.ThkClaims) error {
-	//room, err := w.FindRoomById(id, claims)
-	//if err != nil {
-	//	return err
-	//}
-	//if room == nil {
-	//	return nil
-	//}
-	//
-	//needDestroy := true
-	//for _, p := range room.Participants {
-	//	if p.StreamKey != "" {
-	//		statusResp, errStatus := w.api().GetSessionState(p.StreamKey)
-	//		if errStatus != nil {
-	//			w.appCtx.Logger().Errorf("checkRoom %s %v", id, errStatus)
-	//			needDestroy = false
-	//			break
-	//		}
-	//		if len(statusResp.Tracks) > 0 || len(statusResp.DataChannels) > 0 {
-	//			// 还在通话，放回房间
-	//			needDestroy = false
-	//			break
-	//		}
-	//	}
-	//}
-	//timeout := time.Now().UnixMilli() - room.CreateTime
-	//
-	//if needDestroy && (timeout > 60*1000) {
-	//	err = w.DestroyRoom(id, claims)
-	//	if err != nil {
-	//		w.appCtx.Logger().Errorf("checkRoom %s %v", id, err)
-	//		return err
-	//	}
-	//} else {
-	//	_, err = w.appCtx.RedisCache().SAdd(context.Background(), RoomsKey, []string{id}).Result()
-	//	if err != nil {
-	//		w.appCtx.Logger().Errorf("checkRoom %s %v", id, err)
-	//		return err
-	//	}
-	//}
+			e.appCtx.Logger().Error("CloseTracks err, ", err)
+			return baseErr.ErrInternalServerError
+		}
+		if resp == nil || resp.ErrorCode != "" {
+			e.appCtx.Logger().Error("CloseTracks resp err", resp)
+			return baseErr.ErrInternalServerError
+		}
+	}
+	return nil
+}
+
+func (e cloudflareSFUEngine) CloseAdapters(adapterIds []string) error {
+	req := &dto.CloseAdapterRequest{Tracks: make([]dto.CloseAdapterObject, 0, len(adapterIds))}
+	for _, adapterId := range adapterIds {
+		req.Tracks = append(req.Tracks, dto.CloseAdapterObject{AdapterID: adapterId})
+	}
+	resp, err := e.api().CloseAdapter(req)
+	if err != nil {
+		e.appCtx.Logger().Error("CloseAdapters err, ", err)
+		return baseErr.ErrInternalServerError
+	}
+	if resp == nil || resp.ErrorCode != "" {
+		e.appCtx.Logger().Error("CloseAdapters resp err", resp)
+		return baseErr.ErrInternalServerError
+	}
 	return nil
 }
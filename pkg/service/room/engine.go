@@ -0,0 +1,69 @@
+package room
+
+import (
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/app"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+)
+
+// Engine 房间RTC引擎, 封装与具体媒体服务相关的操作, 按dto.Room.Engine注册; 推拉流时room已通过成员校验
+type Engine interface {
+	// Name 引擎名称, 对应dto.Room.Engine
+	Name() string
+	// IsParticipantAlive 参与人的媒体会话是否存活
+	IsParticipantAlive(participant *dto.Participant) (bool, error)
+	// PublishStream 推流
+	PublishStream(room *dto.Room, req *dto.PublishStreamReq, claims baseDto.ThkClaims) (*dto.PublishStreamResp, error)
+	// SubscribeStream 拉流
+	SubscribeStream(room *dto.Room, req *dto.SubscribeStreamReq, claims baseDto.ThkClaims) (*dto.SubscribeStreamResp, error)
+}
+
+// TrackCloser 支持在服务端强制关闭track的引擎, track按所在会话关闭
+type TrackCloser interface {
+	CloseTracks(tracks []*dto.ParticipantTrack) error
+}
+
+// AdapterCloser 支持WebSocket适配器的引擎, 房间销毁时关闭剩余的适配器
+type AdapterCloser interface {
+	CloseAdapters(adapterIds []string) error
+}
+
+// DataChannel 支持数据通道的引擎, 会话归属已校验
+type DataChannel interface {
+	EstablishDataChannelTransport(room *dto.Room, req *dto.DataChannelTransportReq, claims baseDto.ThkClaims) (*dto.DataChannelTransportResp, error)
+	PublishDataChannel(room *dto.Room, req *dto.PublishDataChannelReq, claims baseDto.ThkClaims) (*dto.DataChannelResp, error)
+	SubscribeDataChannel(room *dto.Room, req *dto.SubscribeDataChannelReq, claims baseDto.ThkClaims) (*dto.DataChannelResp, error)
+	CloseDataChannel(room *dto.Room, req *dto.CloseDataChannelReq, claims baseDto.ThkClaims) error
+}
+
+// SessionUpdater 支持在已有会话上重新协商和增改track的引擎, 会话归属已校验
+type SessionUpdater interface {
+	Renegotiate(room *dto.Room, req *dto.RenegotiateReq, claims baseDto.ThkClaims) (*dto.RenegotiateResp, error)
+	AddTracks(room *dto.Room, req *dto.StreamTracksReq, claims baseDto.ThkClaims) (*dto.StreamTracksResp, error)
+	UpdateTracks(room *dto.Room, req *dto.StreamTracksReq, claims baseDto.ThkClaims) (*dto.StreamTracksResp, error)
+}
+
+// loadEngines 按已配置的sdk注册可用的引擎, 引擎通过service记录成员的推拉流
+func loadEngines(appCtx *app.Context, service Service) map[string]Engine {
+	engines := make(map[string]Engine)
+	if appCtx.WebRTCApi() != nil {
+		engines[dto.EngineWebRTC] = newWebRTCEngine(appCtx, service)
+	}
+	if appCtx.CloudflareConnectApi() != nil {
+		engines[dto.EngineCloudflareSFU] = newCloudflareSFUEngine(appCtx, service)
+	}
+	return engines
+}
+
+// defaultEngine 部署默认引擎, 未配置时使用WebRTC
+func defaultEngine(appCtx *app.Context) string {
+	engine := appCtx.LiveCallConfig().Engine
+	if engine == "" {
+		return dto.EngineWebRTC
+	}
+	return engine
+}
+
+func (r roomService) Engine(name string) Engine {
+	return r.engines[name]
+}
@@ -0,0 +1,60 @@
+package room
+
+import (
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/model"
+)
+
+// saveCallHistory 房间结束时保存通话记录和参与人通话记录, 未配置数据库时忽略
+func (r roomService) saveCallHistory(room *dto.Room, endTime int64) error {
+	recordModel := r.appCtx.CallRecordModel()
+	legModel := r.appCtx.CallLegModel()
+	if recordModel == nil || legModel == nil {
+		return nil
+	}
+	sessionId := int64(0)
+	if room.SessionId != nil {
+		sessionId = *room.SessionId
+	}
+
+	callMsg := dto.BuildCallMsg(room)
+	legs := make([]*model.CallLeg, 0, len(room.Participants))
+	for _, p := range room.Participants {
+		legs = append(legs, &model.CallLeg{
+			RoomId:     room.Id,
+			UId:        p.UId,
+			SessionId:  sessionId,
+			OwnerId:    room.OwnerId,
+			Mode:       room.Mode,
+			Engine:     room.Engine,
+			Role:       p.Role,
+			JoinTime:   p.JoinTime,
+			LeaveTime:  p.LeaveTime,
+			RefuseTime: p.RefuseTime,
+			KickTime:   p.KickTime,
+			Duration:   p.CallDuration(endTime),
+			CreateTime: room.CreateTime,
+			EndTime:    endTime,
+		})
+	}
+	record := &model.CallRecord{
+		RoomId:      room.Id,
+		SessionId:   sessionId,
+		OwnerId:     room.OwnerId,
+		Mode:        room.Mode,
+		Engine:      room.Engine,
+		Accepted:    callMsg.Accepted,
+		AcceptTime:  callMsg.AcceptTime,
+		Duration:    callMsg.Duration,
+		MemberCount: len(callMsg.JoinedUIds),
+		CreateTime:  room.CreateTime,
+		EndTime:     endTime,
+	}
+	if err := recordModel.InsertCallRecord(record); err != nil {
+		return err
+	}
+	if len(legs) == 0 {
+		return nil
+	}
+	return legModel.InsertCallLegs(legs)
+}
@@ -0,0 +1,77 @@
+package room
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+)
+
+// RoomBanKey 房间禁止加入名单, member为用户id, score为解禁时间, +inf表示房间结束前一直禁止
+const RoomBanKey = "live_server:room:%s:ban"
+
+func (r roomService) KickMember(id string, uId int64, banExpireTime int64, claims baseDto.ThkClaims) (*dto.Participant, error) {
+	score := math.Inf(1)
+	if banExpireTime > 0 {
+		score = float64(banExpireTime)
+	}
+	banKey := r.getRoomBanCacheKey(id)
+	_, err := r.appCtx.RoomCache().ZAdd(banKey, score, fmt.Sprintf("%d", uId))
+	if err != nil {
+		return nil, err
+	}
+	// 禁止名单不超过房间的存活时间
+	if ttl, errTTL := r.appCtx.RoomCache().TTL(r.getRoomCacheKey(id)); errTTL == nil && ttl > 0 {
+		_ = r.appCtx.RoomCache().Expire(banKey, ttl)
+	}
+
+	var kicked *dto.Participant
+	now := time.Now().UnixMilli()
+	_, err = r.updateParticipant(id, uId, func(participant *dto.Participant) (*dto.Participant, error) {
+		kicked = nil
+		if participant == nil {
+			return nil, nil
+		}
+		copied := *participant
+		kicked = &copied
+		participant.KickTime = now
+		participant.TransitState(dto.CallStateKicked, now, dto.EndReasonKicked)
+		if participant.JoinTime > 0 && participant.LeaveTime == 0 {
+			participant.LeaveTime = now
+		}
+		// 踢出前的加入/推流事件延迟到达时不再生效
+		participant.EventTime, participant.StreamEventTime = now, now
+		participant.StreamKey = ""
+		participant.Tracks = nil
+		participant.Subscriptions = nil
+		participant.DataChannels = nil
+		participant.HandTime = 0
+		return participant, nil
+	})
+	if err != nil || kicked == nil {
+		return nil, err
+	}
+	r.clearUserRoom(id, uId)
+	return kicked, nil
+}
+
+func (r roomService) IsMemberBanned(id string, uId int64, claims baseDto.ThkClaims) (bool, error) {
+	banKey := r.getRoomBanCacheKey(id)
+	member := fmt.Sprintf("%d", uId)
+	score, existed, err := r.appCtx.RoomCache().ZScore(banKey, member)
+	if err != nil || !existed {
+		return false, err
+	}
+	if score > float64(time.Now().UnixMilli()) {
+		return true, nil
+	}
+	// 已过解禁时间
+	_, _ = r.appCtx.RoomCache().ZRem(banKey, member)
+	return false, nil
+}
+
+func (r roomService) getRoomBanCacheKey(roomId string) string {
+	return fmt.Sprintf(RoomBanKey, roomId)
+}
@@ -0,0 +1,77 @@
+package room
+
+import (
+	"github.com/thk-im/thk-im-livecall-server/pkg/conf"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+)
+
+var (
+	// 内置的视频模式参数, 1280x720@30 4Mbps/192kbps
+	videoDefaultParams = conf.MediaParams{
+		VideoMaxBitrate: 512 * 8 * 1024,
+		VideoWidth:      1280,
+		VideoHeight:     720,
+		VideoFps:        30,
+		AudioMaxBitrate: 24 * 8 * 1024,
+	}
+	videoMaxParams = conf.MediaParams{
+		VideoMaxBitrate: 1024 * 8 * 1024,
+		VideoWidth:      1920,
+		VideoHeight:     1080,
+		VideoFps:        60,
+		AudioMaxBitrate: 64 * 8 * 1024,
+	}
+	// 内置的语音模式参数, 不推视频
+	audioDefaultParams = conf.MediaParams{AudioMaxBitrate: 24 * 8 * 1024}
+	audioMaxParams     = conf.MediaParams{AudioMaxBitrate: 64 * 8 * 1024}
+)
+
+// mediaProfile 房间模式的默认和最大媒体参数, 未配置时使用内置参数
+func (r roomService) mediaProfile(mode int) (*conf.MediaParams, *conf.MediaParams) {
+	defaultParams, maxParams := &videoDefaultParams, &videoMaxParams
+	if dto.IsAudioMode(mode) {
+		defaultParams, maxParams = &audioDefaultParams, &audioMaxParams
+	}
+	for _, profile := range r.appCtx.LiveCallConfig().MediaProfiles {
+		if profile.Mode != mode {
+			continue
+		}
+		if profile.Default != nil {
+			defaultParams = profile.Default
+		}
+		if profile.Max != nil {
+			maxParams = profile.Max
+		}
+	}
+	return defaultParams, maxParams
+}
+
+// mediaParams 客户端未传的参数取默认值, 超过最大值的取最大值, 语音模式不推视频
+func (r roomService) mediaParams(mode int, params *dto.MediaParams) *dto.MediaParams {
+	defaultParams, maxParams := r.mediaProfile(mode)
+	if params == nil {
+		params = &dto.MediaParams{}
+	}
+	mediaParams := &dto.MediaParams{
+		VideoMaxBitrate: clampMediaParam(params.VideoMaxBitrate, defaultParams.VideoMaxBitrate, maxParams.VideoMaxBitrate),
+		VideoWidth:      clampMediaParam(params.VideoWidth, defaultParams.VideoWidth, maxParams.VideoWidth),
+		VideoHeight:     clampMediaParam(params.VideoHeight, defaultParams.VideoHeight, maxParams.VideoHeight),
+		VideoFps:        clampMediaParam(params.VideoFps, defaultParams.VideoFps, maxParams.VideoFps),
+		AudioMaxBitrate: clampMediaParam(params.AudioMaxBitrate, defaultParams.AudioMaxBitrate, maxParams.AudioMaxBitrate),
+	}
+	if dto.IsAudioMode(mode) {
+		mediaParams.VideoMaxBitrate, mediaParams.VideoWidth, mediaParams.VideoHeight, mediaParams.VideoFps = 0, 0, 0, 0
+	}
+	return mediaParams
+}
+
+// clampMediaParam maxValue为0表示不限制
+func clampMediaParam(value, defaultValue, maxValue int) int {
+	if value <= 0 {
+		value = defaultValue
+	}
+	if maxValue > 0 && value > maxValue {
+		value = maxValue
+	}
+	return value
+}
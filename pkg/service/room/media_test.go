@@ -0,0 +1,25 @@
+package room
+
+import "testing"
+
+func TestClampMediaParam(t *testing.T) {
+	cases := []struct {
+		name                       string
+		value, defaultValue, limit int
+		want                       int
+	}{
+		{"in range", 720, 480, 1080, 720},
+		{"zero uses default", 0, 480, 1080, 480},
+		{"negative uses default", -1, 480, 1080, 480},
+		{"over limit", 2160, 480, 1080, 1080},
+		{"default over limit", 0, 2160, 1080, 1080},
+		{"no limit", 2160, 480, 0, 2160},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := clampMediaParam(c.value, c.defaultValue, c.limit); got != c.want {
+				t.Fatalf("got %d, want %d", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package room
+
+import (
+	"math"
+	"time"
+
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+)
+
+const (
+	// ParticipantHeartbeatKey 成员心跳, member为roomId|uId, score为最近一次心跳时间, 首次上报心跳后开始检查
+	ParticipantHeartbeatKey = "live_server:participant:heartbeat"
+
+	participantHeartbeatBatch = 100
+)
+
+func (r roomService) OnMemberHeartbeat(id string, uId int64, claims baseDto.ThkClaims) error {
+	member := participantHeartbeatMember(id, uId)
+	if _, err := r.appCtx.RoomCache().ZAdd(ParticipantHeartbeatKey, float64(time.Now().UnixMilli()), member); err != nil {
+		return err
+	}
+	r.setUserRoom(id, uId)
+	return nil
+}
+
+func (r roomService) CheckMemberHeartbeat() error {
+	claims := newTaskClaims("CheckMemberHeartbeat")
+	now := time.Now().UnixMilli()
+	deadline := now - r.heartbeatTimeout()*1000
+	members, err := r.appCtx.RoomCache().ZRangeByScore(ParticipantHeartbeatKey, math.Inf(-1), float64(deadline), participantHeartbeatBatch)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		// 只删除仍然超时的心跳, 已被其他节点处理或重新上报了心跳的跳过
+		removed, errRem := r.appCtx.RoomCache().ZRemIfScoreBelow(ParticipantHeartbeatKey, member, float64(deadline))
+		if errRem != nil || !removed {
+			continue
+		}
+		roomId, uId, ok := parseParticipantHeartbeatMember(member)
+		if !ok {
+			continue
+		}
+		participant, errFind := r.findParticipant(roomId, uId)
+		if errFind != nil {
+			r.appCtx.Logger().Errorf("CheckMemberHeartbeat %s %v", member, errFind)
+			continue
+		}
+		// 已离开或已被踢出的成员无需再处理
+		if participant == nil || participant.JoinTime == 0 || participant.LeaveTime > 0 {
+			continue
+		}
+		r.appCtx.Logger().Tracef("CheckMemberHeartbeat member timeout %s", member)
+		errLeave := r.OnUserLeaveEvent(&dto.RoomUserLevelEvent{
+			RoomId:    roomId,
+			UserId:    uId,
+			Timestamp: now,
+			Reason:    dto.EndReasonNetwork,
+		}, claims)
+		if errLeave != nil {
+			r.appCtx.Logger().Errorf("CheckMemberHeartbeat %s %v", member, errLeave)
+		}
+	}
+	return nil
+}
+
+// isMemberHeartbeatAlive 成员最近一次心跳是否未超时, 未上报过心跳返回false
+func (r roomService) isMemberHeartbeatAlive(id string, uId int64) (bool, error) {
+	score, existed, err := r.appCtx.RoomCache().ZScore(ParticipantHeartbeatKey, participantHeartbeatMember(id, uId))
+	if err != nil || !existed {
+		return false, err
+	}
+	return int64(score) > time.Now().UnixMilli()-r.heartbeatTimeout()*1000, nil
+}
+
+func (r roomService) removeMemberHeartbeat(id string, uId int64) {
+	_, _ = r.appCtx.RoomCache().ZRem(ParticipantHeartbeatKey, participantHeartbeatMember(id, uId))
+}
+
+func participantHeartbeatMember(roomId string, uId int64) string {
+	return encodeTaskMember(roomId, uId)
+}
+
+func parseParticipantHeartbeatMember(member string) (string, int64, bool) {
+	roomId, uId, _, ok := decodeTaskMember(member, 0)
+	return roomId, uId, ok
+}
@@ -0,0 +1,64 @@
+package room
+
+import (
+	"testing"
+	"time"
+
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+)
+
+// 心跳超时的在线成员离开房间, 已离开的成员和重新上报了心跳的成员不处理
+func TestCheckMemberHeartbeatLocalCache(t *testing.T) {
+	r := newTestService(t)
+	saveTestRoom(t, r, &dto.Room{
+		Id:      "room",
+		Mode:    dto.ModeVoiceRoom,
+		OwnerId: 1,
+		Status:  dto.RoomStatusActive,
+		Participants: []*dto.Participant{
+			{UId: 1, JoinTime: 1, State: dto.CallStateAccepted},
+			{UId: 2, JoinTime: 1, State: dto.CallStateAccepted},
+			{UId: 3, JoinTime: 1, LeaveTime: 2, State: dto.CallStateLeft, EndReason: dto.EndReasonHangup},
+			{UId: 4, JoinTime: 1, State: dto.CallStateAccepted},
+		},
+	})
+	stale := float64(time.Now().UnixMilli() - (defaultHeartbeatTimeout+1)*1000)
+	for _, uId := range []int64{2, 3, 4} {
+		if _, err := r.appCtx.RoomCache().ZAdd(ParticipantHeartbeatKey, stale, participantHeartbeatMember("room", uId)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, uId := range []int64{1, 4} {
+		if err := r.OnMemberHeartbeat("room", uId, baseDto.ThkClaims{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := r.CheckMemberHeartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		uId       int64
+		state     int
+		endReason string
+		alive     bool
+	}{
+		{1, dto.CallStateAccepted, "", true},
+		{2, dto.CallStateLeft, dto.EndReasonNetwork, false},
+		{3, dto.CallStateLeft, dto.EndReasonHangup, false},
+		{4, dto.CallStateAccepted, "", true},
+	}
+	for _, c := range cases {
+		p := findTestParticipant(t, r, "room", c.uId)
+		if p.State != c.state || p.EndReason != c.endReason {
+			t.Errorf("member %d state %d reason %s, want %d %s", c.uId, p.State, p.EndReason, c.state, c.endReason)
+		}
+		if alive, err := r.isMemberHeartbeatAlive("room", c.uId); err != nil || alive != c.alive {
+			t.Errorf("member %d alive %v, want %v, err %v", c.uId, alive, c.alive, err)
+		}
+	}
+	if room := findTestRoom(t, r, "room"); room == nil || room.Status != dto.RoomStatusActive {
+		t.Fatalf("room ended %+v", room)
+	}
+}
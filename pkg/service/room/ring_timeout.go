@@ -0,0 +1,141 @@
+package room
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+)
+
+const (
+	// RingTimeoutKey 响铃超时队列, member为 房间id|被请求人id|请求人id, score为超时时间
+	RingTimeoutKey       = "live_server:ring_timeout"
+	ringTimeoutBatchSize = 100
+)
+
+func (r roomService) ScheduleRingTimeout(id string, requestUId int64, uIds []int64, timeoutTime int64, claims baseDto.ThkClaims) error {
+	if len(uIds) == 0 {
+		return nil
+	}
+	members := make([]string, 0, len(uIds))
+	for _, uId := range uIds {
+		members = append(members, r.getRingTimeoutMember(id, uId, requestUId))
+	}
+	_, err := r.appCtx.RoomCache().ZAdd(RingTimeoutKey, float64(timeoutTime), members...)
+	return err
+}
+
+func (r roomService) CheckRingTimeout() error {
+	claims := newTaskClaims("CheckRingTimeout")
+	now := time.Now().UnixMilli()
+	members, err := r.appCtx.RoomCache().ZRangeByScore(RingTimeoutKey, math.Inf(-1), float64(now), ringTimeoutBatchSize)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		// 删除成功的节点负责处理, 避免多个节点重复处理
+		removed, errRem := r.appCtx.RoomCache().ZRem(RingTimeoutKey, member)
+		if errRem != nil {
+			return errRem
+		}
+		if removed == 0 {
+			continue
+		}
+		id, uId, requestUId, errParse := r.parseRingTimeoutMember(member)
+		if errParse != nil {
+			r.appCtx.Logger().Errorf("CheckRingTimeout parse %s %v", member, errParse)
+			continue
+		}
+		if errTimeout := r.onRingTimeout(id, uId, requestUId, now, claims); errTimeout != nil {
+			r.appCtx.Logger().Errorf("CheckRingTimeout %s %v", member, errTimeout)
+		}
+	}
+	return nil
+}
+
+func (r roomService) onRingTimeout(id string, uId, requestUId, timeoutTime int64, claims baseDto.ThkClaims) error {
+	room, errRoom := r.FindRoomById(id, claims)
+	if errRoom != nil {
+		return errRoom
+	}
+	if room == nil {
+		return nil
+	}
+	updated, err := r.updateParticipant(id, uId, func(participant *dto.Participant) (*dto.Participant, error) {
+		// 已接听、已拒绝、已超时或已取消的不再处理, 重新邀请的成员按当前呼叫状态判断
+		if participant == nil || !participant.IsRinging() {
+			return nil, nil
+		}
+		participant.TimeoutTime = timeoutTime
+		participant.TransitState(dto.CallStateTimeout, timeoutTime, dto.EndReasonTimeout)
+		return participant, nil
+	})
+	if err != nil || updated == nil {
+		return err
+	}
+	for _, p := range room.Participants {
+		if p.UId == uId {
+			*p = *updated
+		}
+	}
+	r.appCtx.Logger().Tracef("onRingTimeout %s %d %d", id, uId, requestUId)
+
+	cancelSignal := dto.MakeCancelRequestingSignal(room.Id, "", room.CreateTime, timeoutTime)
+	if err := r.signalService.PushSignal(cancelSignal, []int64{uId}, claims); err != nil {
+		r.appCtx.Logger().Error("onRingTimeout cancelSignal", id, uId, err)
+	}
+	noAnswerSignal := dto.MakeNoAnswerSignal(room.Id, uId, timeoutTime)
+	if err := r.signalService.PushSignal(noAnswerSignal, []int64{requestUId}, claims); err != nil {
+		r.appCtx.Logger().Error("onRingTimeout noAnswerSignal", id, requestUId, err)
+	}
+
+	// 除请求人外没有人在通话中或等待接听, 销毁房间
+	count := 0
+	for _, p := range room.Participants {
+		if p.UId == requestUId {
+			continue
+		}
+		if p.JoinTime > 0 && p.LeaveTime == 0 {
+			count++
+		} else if p.IsRinging() {
+			count++
+		}
+	}
+	if count == 0 {
+		return r.DestroyRoom(room.Id, 0, dto.EndReasonTimeout, claims)
+	}
+	return nil
+}
+
+// hasPendingRingTimeout 房间内是否有响铃中的成员还在等待响铃超时处理
+func (r roomService) hasPendingRingTimeout(room *dto.Room) (bool, error) {
+	for _, p := range room.Participants {
+		if !p.IsRinging() {
+			continue
+		}
+		_, existed, err := r.appCtx.RoomCache().ZScore(RingTimeoutKey, r.getRingTimeoutMember(room.Id, p.UId, p.InviterId))
+		if err != nil || existed {
+			return existed, err
+		}
+	}
+	return false, nil
+}
+
+func (r roomService) getRingTimeoutMember(roomId string, uId, requestUId int64) string {
+	return encodeTaskMember(roomId, uId, strconv.FormatInt(requestUId, 10))
+}
+
+func (r roomService) parseRingTimeoutMember(member string) (string, int64, int64, error) {
+	roomId, uId, fields, ok := decodeTaskMember(member, 1)
+	if !ok {
+		return "", 0, 0, fmt.Errorf("invalid ring timeout member %s", member)
+	}
+	requestUId, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return roomId, uId, requestUId, nil
+}
@@ -0,0 +1,67 @@
+package room
+
+import (
+	"testing"
+	"time"
+
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+)
+
+// 到期的响铃超时只处理仍在响铃的成员, 没有剩余被叫时结束房间
+func TestCheckRingTimeoutLocalCache(t *testing.T) {
+	r := newTestService(t)
+	saveTestRoom(t, r, &dto.Room{
+		Id:      "room",
+		Mode:    dto.ModeAudio,
+		OwnerId: 1,
+		Status:  dto.RoomStatusRinging,
+		Participants: []*dto.Participant{
+			{UId: 1, JoinTime: 1, State: dto.CallStateAccepted},
+			{UId: 2, InviterId: 1, State: dto.CallStateRinging},
+			{UId: 3, InviterId: 1, State: dto.CallStateRinging},
+			{UId: 4, InviterId: 1, State: dto.CallStateDeclined, EndReason: dto.EndReasonDeclined},
+		},
+	})
+	now := time.Now().UnixMilli()
+	claims := baseDto.ThkClaims{}
+	if err := r.ScheduleRingTimeout("room", 1, []int64{2, 4}, now-1000, claims); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ScheduleRingTimeout("room", 1, []int64{3}, now+60000, claims); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.CheckRingTimeout(); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		uId   int64
+		state int
+	}{
+		{2, dto.CallStateTimeout},
+		{3, dto.CallStateRinging},
+		{4, dto.CallStateDeclined},
+	}
+	for _, c := range cases {
+		if p := findTestParticipant(t, r, "room", c.uId); p.State != c.state {
+			t.Errorf("member %d state %d, want %d", c.uId, p.State, c.state)
+		}
+	}
+	room := findTestRoom(t, r, "room")
+	if room.Status == dto.RoomStatusEnded {
+		t.Fatal("room ended with ringing callee")
+	}
+	if pending, err := r.hasPendingRingTimeout(room); err != nil || !pending {
+		t.Fatalf("pending ring timeout %v %v", pending, err)
+	}
+
+	// 最后一个被叫超时后房间结束
+	if err := r.onRingTimeout("room", 3, 1, now, claims); err != nil {
+		t.Fatal(err)
+	}
+	room = findTestRoom(t, r, "room")
+	if room.Status != dto.RoomStatusEnded || room.EndReason != dto.EndReasonTimeout {
+		t.Fatalf("unexpected room %+v", room)
+	}
+}
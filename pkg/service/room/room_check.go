@@ -0,0 +1,128 @@
+package room
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+)
+
+const (
+	// RoomIdleKey 房间首次被检查到没有媒体流的时间
+	RoomIdleKey = "live_server:room:%s:idle_t"
+
+	defaultRoomCheckInterval    = 30
+	defaultRoomCheckGracePeriod = 60
+	defaultRingGracePeriod      = 120
+)
+
+func (r roomService) checkRooms() error {
+	claims := newTaskClaims("CheckRooms")
+	ids, err := r.appCtx.RoomCache().SMembers(RoomsKey)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if errCheck := r.checkRoom(id, claims); errCheck != nil {
+			r.appCtx.Logger().Errorf("checkRoom %s %v", id, errCheck)
+		}
+	}
+	return nil
+}
+
+func (r roomService) checkRoom(id string, claims baseDto.ThkClaims) error {
+	room, err := r.FindRoomById(id, claims)
+	if err != nil {
+		return err
+	}
+	if room == nil {
+		// 房间已过期, 清理残留的参与人和索引
+		r.appCtx.Logger().Tracef("checkRoom clean expired room %s", id)
+		if errDel := r.appCtx.RoomCache().Del(r.getParticipantsCacheKey(id), r.getRoomIdleCacheKey(id), r.getRoomBanCacheKey(id), r.getRoomAdaptersCacheKey(id)); errDel != nil {
+			return errDel
+		}
+		_, errRem := r.appCtx.RoomCache().SRem(RoomsKey, id)
+		return errRem
+	}
+
+	alive, errAlive := r.hasLiveMedia(room)
+	gracePeriod := r.roomCheckGracePeriod()
+	if errAlive == nil && !alive && room.Status == dto.RoomStatusRinging {
+		// 呼叫中的房间由响铃超时处理, 没有待处理的响铃超时(未设置响铃时长或任务丢失)时按响铃宽限期销毁
+		alive, errAlive = r.hasPendingRingTimeout(room)
+		gracePeriod = r.ringGracePeriod()
+	}
+	if errAlive != nil {
+		// 查询失败时不销毁房间, 等待下次检查
+		return errAlive
+	}
+	idleKey := r.getRoomIdleCacheKey(id)
+	if alive {
+		return r.appCtx.RoomCache().Del(idleKey)
+	}
+
+	now := time.Now().UnixMilli()
+	if _, err = r.appCtx.RoomCache().SetNX(idleKey, strconv.FormatInt(now, 10), time.Hour); err != nil {
+		return err
+	}
+	idleValue, errIdle := r.getString(idleKey)
+	if errIdle != nil {
+		return errIdle
+	}
+	idleTime, _ := strconv.ParseInt(idleValue, 10, 64)
+	if idleTime == 0 || now-idleTime < gracePeriod*1000 {
+		return nil
+	}
+	r.appCtx.Logger().Tracef("checkRoom destroy idle room %s, idle since %d", id, idleTime)
+	return r.DestroyRoom(id, 0, dto.EndReasonNetwork, claims)
+}
+
+// hasLiveMedia 房间内是否还有存活的媒体流, 心跳未超时的成员视为存活(观众等未推流的成员), 引擎未注册时以加入/离开时间判断
+func (r roomService) hasLiveMedia(room *dto.Room) (bool, error) {
+	engine := r.engines[room.Engine]
+	for _, p := range room.Participants {
+		if p.JoinTime == 0 || p.LeaveTime > 0 {
+			continue
+		}
+		if engine == nil {
+			return true, nil
+		}
+		heartbeat, errHeartbeat := r.isMemberHeartbeatAlive(room.Id, p.UId)
+		if errHeartbeat != nil {
+			return false, errHeartbeat
+		}
+		if heartbeat {
+			return true, nil
+		}
+		alive, err := engine.IsParticipantAlive(p)
+		if err != nil {
+			return false, err
+		}
+		if alive {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r roomService) roomCheckGracePeriod() int64 {
+	config := r.appCtx.LiveCallConfig().RoomCheck
+	if config == nil || config.GracePeriod <= 0 {
+		return defaultRoomCheckGracePeriod
+	}
+	return config.GracePeriod
+}
+
+func (r roomService) ringGracePeriod() int64 {
+	config := r.appCtx.LiveCallConfig().RoomCheck
+	if config == nil || config.RingGracePeriod <= 0 {
+		return defaultRingGracePeriod
+	}
+	return config.RingGracePeriod
+}
+
+func (r roomService) getRoomIdleCacheKey(roomId string) string {
+	return fmt.Sprintf(RoomIdleKey, roomId)
+}
@@ -0,0 +1,106 @@
+package room
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+)
+
+// 呼叫中的房间有待处理的响铃超时时不销毁, 否则按响铃宽限期销毁
+func TestCheckRoomRingingLocalCache(t *testing.T) {
+	cases := []struct {
+		name        string
+		status      int
+		pendingRing bool
+		idleSeconds int64
+		wantEnded   bool
+	}{
+		{"ringing with pending ring timeout", dto.RoomStatusRinging, true, defaultRingGracePeriod + 1, false},
+		{"ringing within ring grace", dto.RoomStatusRinging, false, defaultRoomCheckGracePeriod + 1, false},
+		{"ringing after ring grace", dto.RoomStatusRinging, false, defaultRingGracePeriod + 1, true},
+		{"active after grace", dto.RoomStatusActive, false, defaultRoomCheckGracePeriod + 1, true},
+		{"active within grace", dto.RoomStatusActive, false, 1, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := newTestService(t)
+			saveTestRoom(t, r, &dto.Room{
+				Id:      "room",
+				Mode:    dto.ModeAudio,
+				OwnerId: 1,
+				Status:  c.status,
+				Participants: []*dto.Participant{
+					{UId: 1, JoinTime: 1, LeaveTime: 2, State: dto.CallStateLeft},
+					{UId: 2, InviterId: 1, State: dto.CallStateRinging},
+				},
+			})
+			now := time.Now().UnixMilli()
+			if c.pendingRing {
+				if err := r.ScheduleRingTimeout("room", 1, []int64{2}, now+60000, baseDto.ThkClaims{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			idleTime := strconv.FormatInt(now-c.idleSeconds*1000, 10)
+			if err := r.appCtx.RoomCache().SetEx(r.getRoomIdleCacheKey("room"), idleTime, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := r.checkRoom("room", baseDto.ThkClaims{}); err != nil {
+				t.Fatal(err)
+			}
+			room := findTestRoom(t, r, "room")
+			if ended := room.Status == dto.RoomStatusEnded; ended != c.wantEnded {
+				t.Fatalf("room status %d, want ended %v", room.Status, c.wantEnded)
+			}
+			idleValue, err := r.getString(r.getRoomIdleCacheKey("room"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.pendingRing && idleValue != "" {
+				t.Fatalf("idle time not cleared %s", idleValue)
+			}
+		})
+	}
+}
+
+// 租约只能被一个节点持有, 持有者可以续期
+func TestSchedulerAcquireLeaderLocalCache(t *testing.T) {
+	r := newTestService(t)
+	first := NewScheduler(r.appCtx, r)
+	second := NewScheduler(r.appCtx, r)
+	cases := []struct {
+		name      string
+		scheduler *Scheduler
+		want      bool
+	}{
+		{"first acquire", first, true},
+		{"second denied", second, false},
+		{"first renew", first, true},
+		{"second still denied", second, false},
+	}
+	for _, c := range cases {
+		isLeader, err := c.scheduler.acquireLeader("task", time.Minute)
+		if err != nil || isLeader != c.want {
+			t.Fatalf("%s leader %v, want %v, err %v", c.name, isLeader, c.want, err)
+		}
+	}
+	// 其他任务的租约互不影响
+	if isLeader, err := second.acquireLeader("other", time.Minute); err != nil || !isLeader {
+		t.Fatalf("other task leader %v %v", isLeader, err)
+	}
+
+	// 租约过期后由其他节点获取, 原持有者不能再续期
+	if isLeader, err := first.acquireLeader("expire", 10*time.Millisecond); err != nil || !isLeader {
+		t.Fatalf("expire task leader %v %v", isLeader, err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if isLeader, err := second.acquireLeader("expire", time.Minute); err != nil || !isLeader {
+		t.Fatalf("lease not taken over %v %v", isLeader, err)
+	}
+	if isLeader, err := first.acquireLeader("expire", time.Minute); err != nil || isLeader {
+		t.Fatalf("expired leader renewed %v %v", isLeader, err)
+	}
+}
@@ -0,0 +1,123 @@
+package room
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/app"
+	"github.com/thk-im/thk-im-livecall-server/pkg/common"
+)
+
+// SchedulerLeaderKey 任务主节点租约, 同一任务同一时间只在一个节点上执行
+const SchedulerLeaderKey = "live_server:scheduler:%s:leader"
+
+// Scheduler 房间后台任务调度器
+type Scheduler struct {
+	appCtx  *app.Context
+	service Service
+	nodeId  string
+}
+
+func NewScheduler(appCtx *app.Context, service Service) *Scheduler {
+	return &Scheduler{
+		appCtx:  appCtx,
+		service: service,
+		nodeId:  common.GenUUid(),
+	}
+}
+
+// Start 启动所有后台任务
+func (s *Scheduler) Start() {
+	go s.run("CheckRingTimeout", time.Second, s.service.CheckRingTimeout)
+	go s.runAsLeader("CheckRooms", s.roomCheckInterval(), s.service.CheckRooms)
+	go s.runAsLeader("CheckStreamHeartbeat", s.heartbeatInterval(), s.service.CheckStreamHeartbeat)
+	go s.runAsLeader("CheckMemberHeartbeat", s.heartbeatInterval(), s.service.CheckMemberHeartbeat)
+}
+
+// runAsLeader 只有持有租约的节点执行任务, 租约时长为两个执行间隔
+func (s *Scheduler) runAsLeader(name string, interval time.Duration, task func() error) {
+	s.run(name, interval, func() error {
+		isLeader, err := s.acquireLeader(name, 2*interval)
+		if err != nil || !isLeader {
+			return err
+		}
+		return task()
+	})
+}
+
+func (s *Scheduler) acquireLeader(name string, ttl time.Duration) (bool, error) {
+	key := fmt.Sprintf(SchedulerLeaderKey, name)
+	success, err := s.appCtx.RoomCache().SetNX(key, s.nodeId, ttl)
+	if err != nil || success {
+		return success, err
+	}
+	// 比较和续期在同一个脚本中完成, 避免租约刚过期被其他节点获取后又被本节点续期
+	return s.appCtx.RoomCache().ExpireIfEqual(key, s.nodeId, ttl)
+}
+
+func (s *Scheduler) roomCheckInterval() time.Duration {
+	config := s.appCtx.LiveCallConfig().RoomCheck
+	if config == nil || config.Interval <= 0 {
+		return defaultRoomCheckInterval * time.Second
+	}
+	return time.Duration(config.Interval) * time.Second
+}
+
+func (s *Scheduler) heartbeatInterval() time.Duration {
+	config := s.appCtx.LiveCallConfig().Heartbeat
+	if config == nil || config.Interval <= 0 {
+		return defaultHeartbeatInterval * time.Second
+	}
+	return time.Duration(config.Interval) * time.Second
+}
+
+func (s *Scheduler) run(name string, interval time.Duration, task func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.runOnce(name, task)
+	}
+}
+
+func (s *Scheduler) runOnce(name string, task func() error) {
+	defer func() {
+		if e := recover(); e != nil {
+			s.appCtx.Logger().Errorf("Scheduler %s panic %v", name, e)
+		}
+	}()
+	if err := task(); err != nil {
+		s.appCtx.Logger().Errorf("Scheduler %s %v", name, err)
+	}
+}
+
+func newTaskClaims(name string) baseDto.ThkClaims {
+	claims := baseDto.ThkClaims{}
+	claims.PutValue(baseDto.TraceID, fmt.Sprintf("%s-%d", name, time.Now().UnixNano()))
+	claims.PutValue(baseDto.SpanID, "1")
+	return claims
+}
+
+// taskMemberSep 定时任务ZSET member的字段分隔符, 响铃超时、成员心跳和推流心跳共用
+const taskMemberSep = "|"
+
+// encodeTaskMember 按顺序拼接定时任务ZSET的member, 第一个字段为房间id
+func encodeTaskMember(roomId string, uId int64, fields ...string) string {
+	parts := append([]string{roomId, strconv.FormatInt(uId, 10)}, fields...)
+	return strings.Join(parts, taskMemberSep)
+}
+
+// decodeTaskMember 拆分encodeTaskMember生成的member, count为附加字段数, 最后一个字段可以包含分隔符
+func decodeTaskMember(member string, count int) (string, int64, []string, bool) {
+	parts := strings.SplitN(member, taskMemberSep, count+2)
+	if len(parts) != count+2 || parts[0] == "" {
+		return "", 0, nil, false
+	}
+	uId, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", 0, nil, false
+	}
+	return parts[0], uId, parts[2:], true
+}
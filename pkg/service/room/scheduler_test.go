@@ -0,0 +1,48 @@
+package room
+
+import "testing"
+
+func TestTaskMember(t *testing.T) {
+	r := roomService{}
+	if member := r.getRingTimeoutMember("room", 2, 1); member != "room|2|1" {
+		t.Fatalf("unexpected ring timeout member %s", member)
+	}
+	roomId, uId, requestUId, err := r.parseRingTimeoutMember("room|2|1")
+	if err != nil || roomId != "room" || uId != 2 || requestUId != 1 {
+		t.Fatalf("unexpected ring timeout %s %d %d %v", roomId, uId, requestUId, err)
+	}
+
+	if member := participantHeartbeatMember("room", 2); member != "room|2" {
+		t.Fatalf("unexpected heartbeat member %s", member)
+	}
+	roomId, uId, ok := parseParticipantHeartbeatMember("room|2")
+	if !ok || roomId != "room" || uId != 2 {
+		t.Fatalf("unexpected heartbeat %s %d", roomId, uId)
+	}
+
+	// streamKey为最后一个字段, 可以包含分隔符
+	member := streamHeartbeatMember("room", 2, "a|b")
+	roomId, uId, streamKey, ok := parseStreamHeartbeatMember(member)
+	if !ok || roomId != "room" || uId != 2 || streamKey != "a|b" {
+		t.Fatalf("unexpected stream heartbeat %s %d %s", roomId, uId, streamKey)
+	}
+}
+
+func TestDecodeTaskMemberInvalid(t *testing.T) {
+	cases := []struct {
+		member string
+		count  int
+	}{
+		{"", 0},
+		{"room", 0},
+		{"|2", 0},
+		{"room|x", 0},
+		{"room|2", 1},
+		{"room:2:1", 1},
+	}
+	for _, c := range cases {
+		if _, _, _, ok := decodeTaskMember(c.member, c.count); ok {
+			t.Errorf("member %q decoded", c.member)
+		}
+	}
+}
@@ -1,20 +1,19 @@
 package room
 
 import (
-	"context"
-	"errors"
 	"fmt"
 	"time"
 
-	"github.com/redis/go-redis/v9"
 	baseDto "github.com/thk-im/thk-im-base-server/dto"
 	baseErr "github.com/thk-im/thk-im-base-server/errorx"
 	"github.com/thk-im/thk-im-livecall-server/pkg/app"
 	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/errorx"
 	"github.com/thk-im/thk-im-livecall-server/pkg/service/signal"
 )
 
 const (
+	RoomsKey                   = "live_server:rooms:"
 	SessionLockerKey           = "live_server:session:lk:%d"
 	SessionKey                 = "live_server:session:%d"
 	RLockerKey                 = "live_server:room:lk:%s"
@@ -24,14 +23,45 @@ const (
 )
 
 type Service interface {
+	// Engine 房间引擎, 未配置该引擎时返回nil
+	Engine(name string) Engine
 	// CreateRoom 创建房间
 	CreateRoom(req *dto.RoomCreateReq, claims baseDto.ThkClaims) (*dto.RoomJoinResp, error)
 	// FindRoomById 通过id查询房间信息
 	FindRoomById(id string, claims baseDto.ThkClaims) (*dto.Room, error)
-	// DestroyRoom  通过id销毁房间
-	DestroyRoom(id string, claims baseDto.ThkClaims) error
-	// AddRoomMember 添加房间成员
-	AddRoomMember(id string, uId int64, claims baseDto.ThkClaims) error
+	// DestroyRoom  通过id销毁房间, 房间经结束中迁移到已结束, 已结束的房间保留一段时间;
+	// uId为结束通话的用户, 系统结束时为0, reason见dto.EndReason*
+	DestroyRoom(id string, uId int64, reason string, claims baseDto.ThkClaims) error
+	// TransitRoomStatus 校验并迁移房间状态, 非法迁移返回errorx.ErrRoomStatusInvalid
+	TransitRoomStatus(id string, status int, claims baseDto.ThkClaims) error
+	// AddRoomMember 添加房间成员, role为dto.Audience或dto.Broadcast, inviterId大于0时成员进入已邀请状态
+	AddRoomMember(id string, uId, inviterId int64, role int, claims baseDto.ThkClaims) error
+	// UpdateMemberRole 修改房间成员角色, 同时结束成员的举手申请
+	UpdateMemberRole(id string, uId int64, role int, claims baseDto.ThkClaims) error
+	// UpdateMemberRoomRole 修改成员权限角色, roomRole为dto.RoomRoleMember或dto.RoomRoleModerator
+	UpdateMemberRoomRole(id string, uId int64, roomRole int, claims baseDto.ThkClaims) error
+	// TransferOwner 房主离开时转移房主, newOwnerId为0时优先选择管理员, 其次最早加入的成员, 返回新房主id
+	TransferOwner(id string, ownerId, newOwnerId int64, claims baseDto.ThkClaims) (int64, error)
+	// UpdateMemberMute 修改成员某类track的静音状态, 返回更新后的成员
+	UpdateMemberMute(id string, uId int64, kind string, muted bool, claims baseDto.ThkClaims) (*dto.Participant, error)
+	// UpdateMemberTracks 记录成员推流的track, 并沿用成员当前的静音状态
+	UpdateMemberTracks(id string, uId int64, tracks []*dto.ParticipantTrack, claims baseDto.ThkClaims) error
+	// AddMemberSubscriptions 记录成员拉流的track
+	AddMemberSubscriptions(id string, uId int64, tracks []*dto.ParticipantTrack, claims baseDto.ThkClaims) error
+	// KickMember 踢出成员并加入禁止名单, banExpireTime为解禁时间(ms), 0表示房间结束前不可再加入, 返回踢出前的成员信息
+	KickMember(id string, uId int64, banExpireTime int64, claims baseDto.ThkClaims) (*dto.Participant, error)
+	// IsMemberBanned 成员是否在房间禁止名单中
+	IsMemberBanned(id string, uId int64, claims baseDto.ThkClaims) (bool, error)
+	// SaveRoomAdapter 记录房间内创建的WebSocket适配器
+	SaveRoomAdapter(id string, adapter *dto.RoomAdapter) error
+	// FindRoomAdapters 查询房间内的WebSocket适配器
+	FindRoomAdapters(id string) ([]*dto.RoomAdapter, error)
+	// DeleteRoomAdapters 删除已关闭的WebSocket适配器
+	DeleteRoomAdapters(id string, adapterIds []string) error
+	// UpdateMember 读取成员信息并通过update修改后保存
+	UpdateMember(id string, uId int64, update func(participant *dto.Participant), claims baseDto.ThkClaims) error
+	// UpdateMemberHand 成员举手/取消举手
+	UpdateMemberHand(id string, uId int64, raise bool, claims baseDto.ThkClaims) error
 	// RefuseJoinRoom 拒绝加入
 	RefuseJoinRoom(id string, uId int64, isBusy bool, claims baseDto.ThkClaims) error
 	// RequestJoinRoom 请求加入房间
@@ -42,31 +72,156 @@ type Service interface {
 	OnUserLeaveEvent(event *dto.RoomUserLevelEvent, claims baseDto.ThkClaims) error
 	// OnUserPushEvent 房间参与人推流事件
 	OnUserPushEvent(event *dto.RoomUserPushStreamEvent, claims baseDto.ThkClaims) error
+	// OnUserStopPushEvent 房间参与人停止推流, 清除StreamKey并关闭引擎中的推流会话
+	OnUserStopPushEvent(event *dto.RoomUserPushStreamEvent, claims baseDto.ThkClaims) error
+	// OnStreamHeartbeat 推流心跳, 首次上报后开始检查心跳超时
+	OnStreamHeartbeat(event *dto.RoomUserPushStreamEvent, claims baseDto.ThkClaims) error
+	// CheckStreamHeartbeat 心跳超时的推流按停止推流处理
+	CheckStreamHeartbeat() error
+	// OnMemberHeartbeat 成员心跳, 首次上报后开始检查心跳超时
+	OnMemberHeartbeat(id string, uId int64, claims baseDto.ThkClaims) error
+	// CheckMemberHeartbeat 心跳超时的成员按离开房间处理
+	CheckMemberHeartbeat() error
+	// FindUserRoom 查询用户当前所在的房间, 不在房间中时返回空
+	FindUserRoom(uId int64, claims baseDto.ThkClaims) (string, error)
 	// CheckRooms 检查房间是否关闭
 	CheckRooms() error
+	// ScheduleRingTimeout 登记被请求人的响铃超时时间, timeoutTime单位ms
+	ScheduleRingTimeout(id string, requestUId int64, uIds []int64, timeoutTime int64, claims baseDto.ThkClaims) error
+	// CheckRingTimeout 处理已到期的响铃超时
+	CheckRingTimeout() error
 }
 
-type baseRoomService struct {
+type roomService struct {
 	appCtx        *app.Context
 	signalService signal.Service
+	engines       map[string]Engine
 }
 
-func (r baseRoomService) CreateRoom(id, engine string, req *dto.RoomCreateReq, claims baseDto.ThkClaims) (*dto.Room, error) {
+func NewRoomService(appCtx *app.Context) Service {
+	r := &roomService{
+		appCtx:        appCtx,
+		signalService: signal.NewSignalService(appCtx),
+	}
+	r.engines = loadEngines(appCtx, r)
+	return r
+}
+
+func (r roomService) CreateRoom(req *dto.RoomCreateReq, claims baseDto.ThkClaims) (*dto.RoomJoinResp, error) {
+	engine := req.Engine
+	if engine == "" {
+		engine = defaultEngine(r.appCtx)
+	}
+	if r.engines[engine] == nil {
+		return nil, errorx.ErrEngineNotSupported
+	}
+
+	lockerKey := fmt.Sprintf(SessionLockerKey, req.SessionId)
+	locker := r.appCtx.NewRoomLocker(lockerKey, 3000, 3000)
+	success, errLock := locker.Lock()
+	if errLock != nil {
+		return nil, errLock
+	}
+	if !success {
+		return nil, baseErr.ErrInternalServerError
+	}
+	defer func() {
+		_, _ = locker.Release()
+	}()
+
+	sessionCacheKey := r.getSessionCacheKey(req.SessionId)
+	roomId, errExist := r.getString(sessionCacheKey)
+	if errExist != nil {
+		return nil, errExist
+	}
+	resp := &dto.RoomJoinResp{}
+	if roomId != "" {
+		room, errRoom := r.FindRoomById(roomId, claims)
+		if errRoom != nil {
+			return nil, errRoom
+		}
+		if room != nil && !room.IsEnded() {
+			for _, p := range room.Participants {
+				if p.UId == req.UId && p.StreamKey != "" {
+					err := r.DestroyRoom(room.Id, req.UId, dto.EndReasonHangup, claims)
+					if err != nil {
+						return nil, err
+					}
+					room.Status = dto.RoomStatusEnded
+					break
+				}
+			}
+			// 已结束的房间不再复用
+			if !room.IsEnded() {
+				banned, errBan := r.IsMemberBanned(room.Id, req.UId, claims)
+				if errBan != nil {
+					return nil, errBan
+				}
+				if banned {
+					return nil, errorx.ErrMemberBanned
+				}
+				resp.Room = room
+			}
+		}
+	}
+
+	if resp.Room == nil {
+		id := r.appCtx.SnowflakeNode().Generate().Base36()
+		room, errCreateRoom := r.newRoom(id, engine, req, claims)
+		if errCreateRoom != nil {
+			return nil, errCreateRoom
+		}
+		if room == nil {
+			return nil, baseErr.ErrInternalServerError
+		}
+		resp.Room = room
+	}
+	_, err := r.appCtx.RoomCache().SAdd(RoomsKey, resp.Room.Id)
+	if err != nil {
+		return nil, err
+	}
+	// 加入已有房间时保留成员的角色和通话状态, 只有房主固定为推流者
+	_, err = r.updateParticipant(resp.Room.Id, req.UId, func(participant *dto.Participant) (*dto.Participant, error) {
+		if resp.Room.OwnerId == req.UId {
+			if participant == nil {
+				participant = &dto.Participant{UId: req.UId}
+			}
+			participant.Role, participant.RoomRole = dto.Broadcast, dto.RoomRoleOwner
+			return participant, nil
+		}
+		if participant == nil {
+			return &dto.Participant{UId: req.UId, Role: dto.DefaultRole(resp.Room.Mode), RoomRole: dto.RoomRoleMember}, nil
+		}
+		return nil, nil
+	})
+	return resp, err
+}
+
+func (r roomService) RequestJoinRoom(req *dto.RoomJoinReq, claims baseDto.ThkClaims) (*dto.RoomJoinResp, error) {
+	room, errRoom := r.FindRoomById(req.RoomId, claims)
+	if errRoom != nil {
+		return nil, errRoom
+	}
+	if room == nil {
+		return nil, errorx.ErrRoomNotExisted
+	}
+	return &dto.RoomJoinResp{
+		Room:  room,
+		Token: "",
+	}, nil
+}
+
+func (r roomService) newRoom(id, engine string, req *dto.RoomCreateReq, claims baseDto.ThkClaims) (*dto.Room, error) {
 	room := &dto.Room{
 		Id:           id,
 		Engine:       engine,
 		Mode:         req.Mode,
 		OwnerId:      req.UId,
-		MediaParams:  req.MediaParams,
+		MediaParams:  r.mediaParams(req.Mode, req.MediaParams),
 		SessionId:    &req.SessionId,
 		CreateTime:   time.Now().UnixMilli(),
 		Participants: make([]*dto.Participant, 0),
 	}
-	room.MediaParams.VideoWidth = 1280
-	room.MediaParams.VideoHeight = 720
-	room.MediaParams.VideoFps = 30
-	room.MediaParams.VideoMaxBitrate = 512 * 8 * 1024
-	room.MediaParams.AudioMaxBitrate = 24 * 8 * 1024 // 24KB
 
 	jsonStr, err := room.Json()
 	if err != nil {
@@ -74,32 +229,32 @@ func (r baseRoomService) CreateRoom(id, engine string, req *dto.RoomCreateReq, c
 	}
 
 	roomCacheKey := r.getRoomCacheKey(id)
-	err = r.appCtx.RedisCache().Set(context.Background(), roomCacheKey, jsonStr, time.Hour).Err()
+	err = r.appCtx.RoomCache().SetEx(roomCacheKey, jsonStr, time.Hour)
 	if err != nil {
 		return nil, err
 	}
 
 	sessionCacheKey := r.getSessionCacheKey(req.SessionId)
-	errSession := r.appCtx.RedisCache().Set(context.Background(), sessionCacheKey, room.Id, time.Minute).Err()
+	errSession := r.appCtx.RoomCache().SetEx(sessionCacheKey, room.Id, time.Minute)
 	if errSession != nil {
 		return nil, errSession
 	}
 	return room, nil
 }
 
-func (r baseRoomService) FindRoomById(id string, claims baseDto.ThkClaims) (*dto.Room, error) {
-	roomJson, err := r.appCtx.RedisCache().Get(context.Background(), r.getRoomCacheKey(id)).Result()
+func (r roomService) FindRoomById(id string, claims baseDto.ThkClaims) (*dto.Room, error) {
+	roomJson, err := r.getString(r.getRoomCacheKey(id))
 	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			return nil, nil
-		}
 		return nil, err
 	}
+	if roomJson == "" {
+		return nil, nil
+	}
 	room, e := dto.NewRoomByJson([]byte(roomJson))
 	if e != nil {
 		return nil, e
 	}
-	members, errMembers := r.appCtx.RedisCache().HVals(context.Background(), r.getParticipantsCacheKey(id)).Result()
+	members, errMembers := r.appCtx.RoomCache().HValues(r.getParticipantsCacheKey(id))
 	if errMembers == nil {
 		participants := make([]*dto.Participant, 0)
 		for _, m := range members {
@@ -112,9 +267,9 @@ func (r baseRoomService) FindRoomById(id string, claims baseDto.ThkClaims) (*dto
 	return room, nil
 }
 
-func (r baseRoomService) DestroyRoom(id string, claims baseDto.ThkClaims) error {
+func (r roomService) DestroyRoom(id string, uId int64, reason string, claims baseDto.ThkClaims) error {
 	lockerKey := fmt.Sprintf(RLockerKey, id)
-	locker := r.appCtx.NewLocker(lockerKey, 3000, 3000)
+	locker := r.appCtx.NewRoomLocker(lockerKey, 3000, 3000)
 	success, errLock := locker.Lock()
 	if errLock != nil {
 		return errLock
@@ -134,94 +289,313 @@ func (r baseRoomService) DestroyRoom(id string, claims baseDto.ThkClaims) error
 		r.appCtx.Logger().Trace("DestroyRoom is nil", id)
 		return nil
 	}
+	// 已经在其他请求中销毁, 结束中的房间为上次清理失败, 需重新清理
+	if roomVo.Status == dto.RoomStatusEnded {
+		return nil
+	}
+	// 重新清理时保留首次结束的原因
+	if roomVo.Status != dto.RoomStatusEnding {
+		roomVo.EndUId, roomVo.EndReason = uId, reason
+	}
+	if err := r.transitRoomStatus(roomVo, dto.RoomStatusEnding, claims); err != nil {
+		return err
+	}
+
+	endTime := roomVo.StatusTime
+	r.endParticipants(roomVo, endTime)
+	if errHistory := r.saveCallHistory(roomVo, endTime); errHistory != nil {
+		r.appCtx.Logger().Error("DestroyRoom saveCallHistory", roomVo, errHistory)
+	}
 
 	r.appCtx.Logger().Trace("DestroyRoom sendLiveCallMsg", id)
 	errSend := r.sendLiveCallEndMsg(roomVo, claims)
 	if errSend != nil {
 		r.appCtx.Logger().Error("DestroyRoom sendLiveCallMsg", roomVo, errSend)
 	}
-	if err := r.appCtx.RedisCache().Del(context.Background(), r.getRoomCacheKey(roomVo.Id)).Err(); err != nil {
-		return err
+	r.closeRoomAdapters(roomVo)
+	for _, p := range roomVo.Participants {
+		r.clearUserRoom(roomVo.Id, p.UId)
 	}
 	if roomVo.SessionId != nil {
-		if err := r.appCtx.RedisCache().Del(context.Background(), r.getSessionCacheKey(*roomVo.SessionId)).Err(); err != nil {
+		if err := r.appCtx.RoomCache().Del(r.getSessionCacheKey(*roomVo.SessionId)); err != nil {
 			return err
 		}
 	}
-	if err := r.appCtx.RedisCache().Del(context.Background(), r.getParticipantsCacheKey(roomVo.Id)).Err(); err != nil {
+	if err := r.appCtx.RoomCache().Del(r.getParticipantsCacheKey(roomVo.Id), r.getRoomIdleCacheKey(roomVo.Id), r.getRoomBanCacheKey(roomVo.Id), r.getRoomAdaptersCacheKey(roomVo.Id)); err != nil {
 		return err
 	}
-
-	return nil
-}
-
-func (r baseRoomService) AddRoomMember(id string, uId int64, claims baseDto.ThkClaims) error {
-	participant := &dto.Participant{
-		UId:    uId,
-		Role:   dto.Broadcast,
-		Refuse: 0,
+	if _, err := r.appCtx.RoomCache().SRem(RoomsKey, roomVo.Id); err != nil {
+		return err
 	}
-	pJson, err := participant.Json()
-	if err != nil {
-		return nil
+	if err := r.transitRoomStatus(roomVo, dto.RoomStatusEnded, claims); err != nil {
+		return err
 	}
+	return r.appCtx.RoomCache().Expire(r.getRoomCacheKey(roomVo.Id), endedRoomExpire)
+}
 
-	cacheKey := r.getParticipantsCacheKey(id)
-	err = r.appCtx.RedisCache().HSet(context.Background(), cacheKey, fmt.Sprintf("%d", uId), pJson).Err()
+func (r roomService) AddRoomMember(id string, uId, inviterId int64, role int, claims baseDto.ThkClaims) error {
+	now := time.Now().UnixMilli()
+	_, err := r.updateParticipant(id, uId, func(participant *dto.Participant) (*dto.Participant, error) {
+		if participant == nil {
+			participant = &dto.Participant{UId: uId, Role: role}
+		} else if participant.JoinTime > 0 && participant.LeaveTime == 0 {
+			// 已在通话中, 无需重新添加
+			return nil, nil
+		}
+		if participant.IsKicked() {
+			// 被踢出的成员重新加入, 角色和权限按新成员处理
+			participant.KickTime = 0
+			participant.Role, participant.RoomRole = role, dto.RoomRoleMember
+		}
+		// 重新呼叫只重置应答状态, 保留角色等信息
+		participant.Refuse, participant.RefuseTime, participant.TimeoutTime = 0, 0, 0
+		if inviterId > 0 && participant.TransitState(dto.CallStateInvited, now, "") {
+			participant.InviterId = inviterId
+		}
+		return participant, nil
+	})
 	return err
 }
 
-func (r baseRoomService) RefuseJoinRoom(id string, uId int64, isBusy bool, claims baseDto.ThkClaims) error {
+func (r roomService) RefuseJoinRoom(id string, uId int64, isBusy bool, claims baseDto.ThkClaims) error {
 	refuse := 1
 	if isBusy {
 		refuse = 2
 	}
-	participant := &dto.Participant{
-		UId:    uId,
-		Role:   dto.Broadcast,
-		Refuse: refuse,
+	now := time.Now().UnixMilli()
+	_, err := r.updateParticipant(id, uId, func(participant *dto.Participant) (*dto.Participant, error) {
+		if participant == nil {
+			participant = &dto.Participant{UId: uId, Role: dto.Broadcast}
+		}
+		// 已接听或已拒绝的重复/过期请求忽略
+		if (participant.JoinTime > 0 && participant.LeaveTime == 0) || participant.Refuse > 0 {
+			return nil, nil
+		}
+		participant.Refuse, participant.RefuseTime = refuse, now
+		reason := dto.EndReasonDeclined
+		if isBusy {
+			reason = dto.EndReasonBusy
+		}
+		participant.TransitState(dto.CallStateDeclined, now, reason)
+		return participant, nil
+	})
+	return err
+}
+
+func (r roomService) UpdateMemberRole(id string, uId int64, role int, claims baseDto.ThkClaims) error {
+	return r.UpdateMember(id, uId, func(participant *dto.Participant) {
+		participant.Role = role
+		participant.HandTime = 0
+	}, claims)
+}
+
+func (r roomService) UpdateMemberRoomRole(id string, uId int64, roomRole int, claims baseDto.ThkClaims) error {
+	_, err := r.updateParticipant(id, uId, func(participant *dto.Participant) (*dto.Participant, error) {
+		if participant == nil {
+			return nil, errorx.ErrNotRoomMember
+		}
+		if participant.RoomRole == dto.RoomRoleOwner {
+			return nil, errorx.ErrNoPermission
+		}
+		participant.RoomRole = roomRole
+		return participant, nil
+	})
+	return err
+}
+
+func (r roomService) TransferOwner(id string, ownerId, newOwnerId int64, claims baseDto.ThkClaims) (int64, error) {
+	lockerKey := fmt.Sprintf(RLockerKey, id)
+	locker := r.appCtx.NewRoomLocker(lockerKey, 3000, 3000)
+	success, errLock := locker.Lock()
+	if errLock != nil {
+		return 0, errLock
+	}
+	if !success {
+		return 0, baseErr.ErrInternalServerError
 	}
-	pJson, err := participant.Json()
-	if err != nil {
-		return nil
+	defer func() {
+		_, _ = locker.Release()
+	}()
+
+	room, errRoom := r.FindRoomById(id, claims)
+	if errRoom != nil {
+		return 0, errRoom
+	}
+	if room == nil {
+		return 0, errorx.ErrRoomNotExisted
+	}
+	// 房主已经转移过
+	if room.OwnerId != ownerId {
+		return room.OwnerId, nil
 	}
 
-	cacheKey := r.getParticipantsCacheKey(id)
-	err = r.appCtx.RedisCache().HSet(context.Background(), cacheKey, fmt.Sprintf("%d", uId), pJson).Err()
+	var oldOwner, newOwner *dto.Participant
+	for _, p := range room.Participants {
+		if p.UId == ownerId {
+			oldOwner = p
+		} else if newOwnerId > 0 && p.UId == newOwnerId {
+			newOwner = p
+		}
+	}
+	if newOwnerId > 0 && newOwner == nil {
+		return 0, errorx.ErrNotRoomMember
+	}
+	if newOwner == nil {
+		newOwner = r.pickNextOwner(room)
+	}
+	if newOwner == nil {
+		return 0, nil
+	}
+
+	room.OwnerId = newOwner.UId
+	if err := r.saveRoom(room); err != nil {
+		return 0, err
+	}
+	if oldOwner != nil {
+		if err := r.UpdateMember(id, oldOwner.UId, func(participant *dto.Participant) {
+			participant.RoomRole = dto.RoomRoleMember
+		}, claims); err != nil {
+			return 0, err
+		}
+	}
+	if err := r.UpdateMember(id, newOwner.UId, func(participant *dto.Participant) {
+		participant.RoomRole = dto.RoomRoleOwner
+	}, claims); err != nil {
+		return 0, err
+	}
+
+	uIds := make([]int64, 0, len(room.Participants))
+	for _, p := range room.Participants {
+		uIds = append(uIds, p.UId)
+	}
+	s := dto.MakeOwnerChangedSignal(id, ownerId, newOwner.UId, time.Now().UnixMilli())
+	if errPush := r.signalService.PushSignal(s, uIds, claims); errPush != nil {
+		r.appCtx.Logger().Error("TransferOwner PushSignal", id, errPush)
+	}
+	return newOwner.UId, nil
+}
+
+// endParticipants 房间结束时结束所有成员的通话状态, 未接听的按取消处理, 房间数据随后删除因此只修改内存中的成员
+func (r roomService) endParticipants(room *dto.Room, endTime int64) {
+	for _, p := range room.Participants {
+		if p.IsRinging() {
+			p.TransitState(dto.CallStateCanceled, endTime, dto.EndReasonRoomEnded)
+		} else if p.State == dto.CallStateAccepted {
+			p.TransitState(dto.CallStateLeft, endTime, dto.EndReasonRoomEnded)
+		}
+	}
+}
+
+// pickNextOwner 在房间内的成员中选择新房主, 管理员优先, 同级按加入时间先后
+func (r roomService) pickNextOwner(room *dto.Room) *dto.Participant {
+	var next *dto.Participant
+	for _, p := range room.Participants {
+		if p.UId == room.OwnerId || p.JoinTime == 0 || p.LeaveTime > 0 || p.KickTime > 0 {
+			continue
+		}
+		if next == nil ||
+			p.RoomRole > next.RoomRole ||
+			(p.RoomRole == next.RoomRole && p.JoinTime < next.JoinTime) {
+			next = p
+		}
+	}
+	return next
+}
+
+func (r roomService) UpdateMemberMute(id string, uId int64, kind string, muted bool, claims baseDto.ThkClaims) (*dto.Participant, error) {
+	return r.updateParticipant(id, uId, func(participant *dto.Participant) (*dto.Participant, error) {
+		if participant == nil {
+			return nil, errorx.ErrNotRoomMember
+		}
+		participant.SetMuted(kind, muted)
+		return participant, nil
+	})
+}
+
+func (r roomService) UpdateMemberTracks(id string, uId int64, tracks []*dto.ParticipantTrack, claims baseDto.ThkClaims) error {
+	return r.UpdateMember(id, uId, func(participant *dto.Participant) {
+		for _, t := range tracks {
+			t.Muted = participant.IsMuted(t.Kind)
+		}
+		participant.Tracks = tracks
+		participant.AddPublishedKinds(tracks)
+	}, claims)
+}
+
+func (r roomService) AddMemberSubscriptions(id string, uId int64, tracks []*dto.ParticipantTrack, claims baseDto.ThkClaims) error {
+	return r.UpdateMember(id, uId, func(participant *dto.Participant) {
+		participant.Subscriptions = append(participant.Subscriptions, tracks...)
+	}, claims)
+}
+
+func (r roomService) UpdateMember(id string, uId int64, update func(participant *dto.Participant), claims baseDto.ThkClaims) error {
+	_, err := r.updateParticipant(id, uId, func(participant *dto.Participant) (*dto.Participant, error) {
+		if participant == nil {
+			return nil, errorx.ErrNotRoomMember
+		}
+		update(participant)
+		return participant, nil
+	})
 	return err
 }
 
-func (r baseRoomService) OnUserJoinEvent(event *dto.RoomUserJoinEvent, claims baseDto.ThkClaims) error {
+func (r roomService) UpdateMemberHand(id string, uId int64, raise bool, claims baseDto.ThkClaims) error {
+	handTime := int64(0)
+	if raise {
+		handTime = time.Now().UnixMilli()
+	}
+	return r.UpdateMember(id, uId, func(participant *dto.Participant) {
+		participant.HandTime = handTime
+	}, claims)
+}
+
+func (r roomService) OnUserJoinEvent(event *dto.RoomUserJoinEvent, claims baseDto.ThkClaims) error {
 	r.appCtx.Logger().Tracef("OnUserJoinEvent %v", event)
 	room, errRoom := r.FindRoomById(event.RoomId, claims)
 	if errRoom != nil {
 		return errRoom
 	}
-	if room == nil {
+	if room == nil || room.IsEnded() {
 		return nil
 	}
-	participant := &dto.Participant{
-		UId:      event.UserId,
-		Role:     dto.Broadcast,
-		JoinTime: event.Timestamp,
-	}
-	pJson, err := participant.Json()
+	// 只合并加入相关的字段, 不覆盖并发写入的推流/应答等信息
+	updated, err := r.updateParticipant(event.RoomId, event.UserId, func(participant *dto.Participant) (*dto.Participant, error) {
+		if participant == nil {
+			participant = &dto.Participant{UId: event.UserId, Role: dto.DefaultRole(room.Mode)}
+		} else if participant.EventTime > event.Timestamp || participant.IsKicked() {
+			// 被踢出的成员需重新加入或被重新邀请后才能再次进入通话
+			return nil, nil
+		}
+		// 重复的加入事件保留首次加入时间
+		if participant.JoinTime == 0 || participant.LeaveTime > 0 {
+			participant.JoinTime = event.Timestamp
+		}
+		participant.LeaveTime = 0
+		participant.EventTime = event.Timestamp
+		participant.TransitState(dto.CallStateAccepted, event.Timestamp, "")
+		return participant, nil
+	})
 	if err != nil {
+		return err
+	}
+	if updated == nil {
+		r.appCtx.Logger().Tracef("OnUserJoinEvent ignore stale event %v", event)
 		return nil
 	}
 	// 房间失效时间+1小时
 	roomCacheKey := r.getRoomCacheKey(event.RoomId)
-	err = r.appCtx.RedisCache().Expire(context.Background(), roomCacheKey, time.Hour).Err()
+	err = r.appCtx.RoomCache().Expire(roomCacheKey, time.Hour)
 	if err != nil {
 		return err
 	}
-
-	cacheKey := r.getParticipantsCacheKey(event.RoomId)
-	err = r.appCtx.RedisCache().HSet(context.Background(), cacheKey, fmt.Sprintf("%d", event.UserId), pJson).Err()
-	return err
+	r.setUserRoom(event.RoomId, event.UserId)
+	if errJoined := r.onMemberJoined(event.RoomId, claims); errJoined != nil {
+		r.appCtx.Logger().Error("OnUserJoinEvent onMemberJoined", event, errJoined)
+	}
+	return nil
 }
 
-func (r baseRoomService) OnUserLeaveEvent(event *dto.RoomUserLevelEvent, claims baseDto.ThkClaims) error {
+func (r roomService) OnUserLeaveEvent(event *dto.RoomUserLevelEvent, claims baseDto.ThkClaims) error {
 	room, errRoom := r.FindRoomById(event.RoomId, claims)
 	if errRoom != nil {
 		return errRoom
@@ -230,20 +604,43 @@ func (r baseRoomService) OnUserLeaveEvent(event *dto.RoomUserLevelEvent, claims
 		return nil
 	}
 
-	for _, p := range room.Participants {
-		if p.UId == event.UserId {
-			p.LeaveTime = event.Timestamp
-			pJson, err := p.Json()
-			if err != nil {
-				return nil
-			}
-			cacheKey := r.getParticipantsCacheKey(event.RoomId)
-			err = r.appCtx.RedisCache().HSet(context.Background(), cacheKey, fmt.Sprintf("%d", event.UserId), pJson).Err()
+	stale := false
+	updated, err := r.updateParticipant(event.RoomId, event.UserId, func(participant *dto.Participant) (*dto.Participant, error) {
+		stale = false
+		if participant == nil {
+			return nil, nil
+		}
+		if participant.EventTime > event.Timestamp {
+			stale = true
+			return nil, nil
+		}
+		// 重复的离开事件保留首次离开时间
+		if participant.LeaveTime == 0 {
+			participant.LeaveTime = event.Timestamp
+		}
+		participant.EventTime = event.Timestamp
+		reason := event.Reason
+		if reason == "" {
+			reason = dto.EndReasonHangup
 		}
+		participant.TransitState(dto.CallStateLeft, event.Timestamp, reason)
+		return participant, nil
+	})
+	if err != nil {
+		return err
+	}
+	if stale {
+		r.appCtx.Logger().Tracef("OnUserLeaveEvent ignore stale event %v", event)
+		return nil
 	}
+	r.removeMemberHeartbeat(event.RoomId, event.UserId)
+	r.clearUserRoom(event.RoomId, event.UserId)
 
 	count := 0
 	for _, p := range room.Participants {
+		if p.UId == event.UserId && updated != nil {
+			p = updated
+		}
 		if p.LeaveTime == 0 && p.JoinTime > 0 {
 			count++
 		}
@@ -251,15 +648,36 @@ func (r baseRoomService) OnUserLeaveEvent(event *dto.RoomUserLevelEvent, claims
 	r.appCtx.Logger().Tracef("OnParticipantLeave %v, user count %d", event, count)
 
 	if count == 0 {
-		errDestroy := r.DestroyRoom(room.Id, claims)
+		reason := dto.EndReasonHangup
+		if updated != nil && updated.EndReason != "" {
+			reason = updated.EndReason
+		}
+		errDestroy := r.DestroyRoom(room.Id, event.UserId, reason, claims)
 		if errDestroy != nil {
 			r.appCtx.Logger().Error("OnParticipantLeave DestroyRoom", event, errDestroy)
 		}
+	} else {
+		if updated != nil {
+			// 离开成员的推流会话不再有数据, 由服务端移除其他成员对这些会话的拉流
+			sessionIds := make(map[string]bool)
+			for _, t := range updated.Tracks {
+				sessionIds[t.SessionId] = true
+			}
+			if updated.StreamKey != "" {
+				sessionIds[updated.StreamKey] = true
+			}
+			r.closeSubscriptions(room, event.UserId, sessionIds)
+		}
+		if event.UserId == room.OwnerId {
+			if _, errTransfer := r.TransferOwner(room.Id, event.UserId, 0, claims); errTransfer != nil {
+				r.appCtx.Logger().Error("OnParticipantLeave TransferOwner", event, errTransfer)
+			}
+		}
 	}
 	return nil
 }
 
-func (r baseRoomService) OnUserPushEvent(event *dto.RoomUserPushStreamEvent, claims baseDto.ThkClaims) error {
+func (r roomService) OnUserPushEvent(event *dto.RoomUserPushStreamEvent, claims baseDto.ThkClaims) error {
 	r.appCtx.Logger().Tracef("OnUserPushEvent %v", event)
 	room, errRoom := r.FindRoomById(event.RoomId, claims)
 	if errRoom != nil {
@@ -269,29 +687,32 @@ func (r baseRoomService) OnUserPushEvent(event *dto.RoomUserPushStreamEvent, cla
 		return nil
 	}
 
+	updated, err := r.updateParticipant(event.RoomId, event.UserId, func(participant *dto.Participant) (*dto.Participant, error) {
+		if participant == nil || participant.StreamEventTime > event.Timestamp {
+			return nil, nil
+		}
+		participant.StreamKey = event.StreamKey
+		participant.StreamEventTime = event.Timestamp
+		return participant, nil
+	})
+	if err != nil {
+		r.appCtx.Logger().Error("OnUserPushEvent updateParticipant", event, err)
+		return nil
+	}
+	if updated == nil {
+		r.appCtx.Logger().Tracef("OnUserPushEvent ignore stale event %v", event)
+		return nil
+	}
+
 	uIds := make([]int64, 0)
 	for _, participant := range room.Participants {
-		if participant.UId == event.UserId {
-			participant.StreamKey = event.StreamKey
-			pJson, err := participant.Json()
-			if err != nil {
-				r.appCtx.Logger().Error("OnUserPushEvent participant Json", event, err, participant)
-				return nil
-			}
-			// 房间参与人失效时间+1小时
-			cacheKey := r.getParticipantsCacheKey(event.RoomId)
-			err = r.appCtx.RedisCache().HSet(context.Background(), cacheKey, fmt.Sprintf("%d", event.UserId), pJson).Err()
-			if err != nil {
-				r.appCtx.Logger().Error("OnUserPushEvent participant Json", event, err, participant)
-			}
-		} else {
+		if participant.UId != event.UserId {
 			uIds = append(uIds, participant.UId)
 		}
 	}
-
 	if len(uIds) > 0 {
 		pushSignal := dto.MakeParticipantPushStreamSignal(event.RoomId, event.StreamKey, event.UserId, event.Timestamp)
-		err := r.signalService.PushSignal(pushSignal, uIds, claims)
+		err = r.signalService.PushSignal(pushSignal, uIds, claims)
 		if err != nil {
 			r.appCtx.Logger().Error("OnUserPushEvent pushSignal", event, err, pushSignal)
 		}
@@ -300,26 +721,99 @@ func (r baseRoomService) OnUserPushEvent(event *dto.RoomUserPushStreamEvent, cla
 	return nil
 }
 
-func (r baseRoomService) CheckRooms() error {
-	return nil
+func (r roomService) CheckRooms() error {
+	return r.checkRooms()
+}
+
+func (r roomService) findParticipant(id string, uId int64) (*dto.Participant, error) {
+	cacheKey := r.getParticipantsCacheKey(id)
+	value, err := r.appCtx.RoomCache().HGet(cacheKey, fmt.Sprintf("%d", uId))
+	if err != nil {
+		return nil, err
+	}
+	pJson, _ := value.(string)
+	if pJson == "" {
+		return nil, nil
+	}
+	return dto.NewParticipantByJson([]byte(pJson))
+}
+
+// updateParticipant 原子地修改成员信息, update的参数在成员不存在时为nil, 返回nil时不写入;
+// 并发冲突重试时update会被多次调用, 返回最终写入的成员, 未写入时返回nil
+func (r roomService) updateParticipant(id string, uId int64, update func(participant *dto.Participant) (*dto.Participant, error)) (*dto.Participant, error) {
+	var updated *dto.Participant
+	cacheKey := r.getParticipantsCacheKey(id)
+	err := r.appCtx.RoomCache().HUpdate(cacheKey, fmt.Sprintf("%d", uId), func(value string) (string, bool, error) {
+		var participant *dto.Participant
+		if value != "" {
+			p, errJson := dto.NewParticipantByJson([]byte(value))
+			if errJson != nil {
+				return "", false, errJson
+			}
+			participant = p
+		}
+		p, errUpdate := update(participant)
+		updated = p
+		if errUpdate != nil || p == nil {
+			return "", false, errUpdate
+		}
+		pJson, errJson := p.Json()
+		if errJson != nil {
+			return "", false, errJson
+		}
+		return pJson, true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// saveRoom 更新房间信息, 保留原有的过期时间
+func (r roomService) saveRoom(room *dto.Room) error {
+	roomVo := *room
+	roomVo.Participants = make([]*dto.Participant, 0)
+	jsonStr, err := roomVo.Json()
+	if err != nil {
+		return err
+	}
+	roomCacheKey := r.getRoomCacheKey(room.Id)
+	ttl, errTTL := r.appCtx.RoomCache().TTL(roomCacheKey)
+	if errTTL != nil {
+		return errTTL
+	}
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return r.appCtx.RoomCache().SetEx(roomCacheKey, jsonStr, ttl)
+}
+
+// getString 读取字符串值, key不存在时返回空字符串
+func (r roomService) getString(key string) (string, error) {
+	value, err := r.appCtx.RoomCache().Get(key)
+	if err != nil || value == nil {
+		return "", err
+	}
+	str, _ := value.(string)
+	return str, nil
 }
 
-func (r baseRoomService) sendLiveCallEndMsg(room *dto.Room, claims baseDto.ThkClaims) error {
+func (r roomService) sendLiveCallEndMsg(room *dto.Room, claims baseDto.ThkClaims) error {
 	return r.signalService.SendLiveCallMsgByEnded(room, claims)
 }
 
-func (r baseRoomService) getRoomCacheKey(roomId string) string {
+func (r roomService) getRoomCacheKey(roomId string) string {
 	return fmt.Sprintf(RCacheKey, roomId)
 }
 
-func (r baseRoomService) getSessionCacheKey(sessionId int64) string {
+func (r roomService) getSessionCacheKey(sessionId int64) string {
 	return fmt.Sprintf(SessionKey, sessionId)
 }
 
-func (r baseRoomService) getParticipantsCacheKey(roomId string) string {
+func (r roomService) getParticipantsCacheKey(roomId string) string {
 	return fmt.Sprintf(ParticipantsKey, roomId)
 }
 
-func (r baseRoomService) getParticipantRequestRoomTimeKey(roomId string, userId int64) string {
+func (r roomService) getParticipantRequestRoomTimeKey(roomId string, userId int64) string {
 	return fmt.Sprintf(ParticipantRequestRoomTime, roomId, userId)
 }
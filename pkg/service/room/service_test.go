@@ -0,0 +1,251 @@
+package room
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/app"
+	"github.com/thk-im/thk-im-livecall-server/pkg/conf"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/service/signal"
+)
+
+// newTestService 基于进程内存储的房间服务, 不依赖redis和数据库
+func newTestService(t *testing.T) roomService {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	appCtx := app.NewLocalContext(&conf.LiveCallConfig{}, logrus.NewEntry(logger))
+	r := &roomService{
+		appCtx:        appCtx,
+		signalService: signal.NewSignalService(appCtx),
+	}
+	r.engines = map[string]Engine{dto.EngineWebRTC: newWebRTCEngine(appCtx, r)}
+	return *r
+}
+
+// saveTestRoom 保存房间和成员, 与CreateRoom写入的数据一致
+func saveTestRoom(t *testing.T, r roomService, room *dto.Room) {
+	t.Helper()
+	if room.Engine == "" {
+		room.Engine = dto.EngineWebRTC
+	}
+	if room.CreateTime == 0 {
+		room.CreateTime = time.Now().UnixMilli()
+	}
+	if err := r.saveRoom(room); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range room.Participants {
+		pJson, err := p.Json()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = r.appCtx.RoomCache().HSet(r.getParticipantsCacheKey(room.Id), fmt.Sprintf("%d", p.UId), pJson, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.appCtx.RoomCache().SAdd(RoomsKey, room.Id); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func findTestRoom(t *testing.T, r roomService, id string) *dto.Room {
+	t.Helper()
+	room, err := r.FindRoomById(id, baseDto.ThkClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return room
+}
+
+func findTestParticipant(t *testing.T, r roomService, id string, uId int64) *dto.Participant {
+	t.Helper()
+	p, err := r.findParticipant(id, uId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestFindRoomByIdLocalCache(t *testing.T) {
+	r := newTestService(t)
+	saveTestRoom(t, r, &dto.Room{
+		Id:           "room",
+		Mode:         dto.ModeAudio,
+		OwnerId:      1,
+		Status:       dto.RoomStatusActive,
+		Participants: []*dto.Participant{{UId: 1, Role: dto.Broadcast}, {UId: 2, Role: dto.Broadcast}},
+	})
+
+	room := findTestRoom(t, r, "room")
+	if room == nil {
+		t.Fatal("room not found")
+	}
+	if room.OwnerId != 1 || room.Status != dto.RoomStatusActive || len(room.Participants) != 2 {
+		t.Fatalf("unexpected room %+v", room)
+	}
+	if missing := findTestRoom(t, r, "missing"); missing != nil {
+		t.Fatalf("unexpected room %+v", missing)
+	}
+}
+
+func TestUpdateMemberConcurrentLocalCache(t *testing.T) {
+	r := newTestService(t)
+	saveTestRoom(t, r, &dto.Room{
+		Id:           "room",
+		OwnerId:      1,
+		Status:       dto.RoomStatusActive,
+		Participants: []*dto.Participant{{UId: 1}, {UId: 2}},
+	})
+
+	// 同一成员的并发修改不超过重试次数时都能写入
+	const times = 10
+	wg := sync.WaitGroup{}
+	for i := 0; i < times; i++ {
+		for _, uId := range []int64{1, 2} {
+			wg.Add(1)
+			go func(uId int64) {
+				defer wg.Done()
+				err := r.UpdateMember("room", uId, func(p *dto.Participant) {
+					p.HandTime++
+				}, baseDto.ThkClaims{})
+				if err != nil {
+					t.Error(err)
+				}
+			}(uId)
+		}
+	}
+	wg.Wait()
+
+	for _, uId := range []int64{1, 2} {
+		if p := findTestParticipant(t, r, "room", uId); p.HandTime != times {
+			t.Fatalf("member %d updated %d times, want %d", uId, p.HandTime, times)
+		}
+	}
+	if err := r.UpdateMember("room", 3, func(p *dto.Participant) {}, baseDto.ThkClaims{}); err == nil {
+		t.Fatal("update missing member should fail")
+	}
+}
+
+func TestDestroyRoomLocalCache(t *testing.T) {
+	r := newTestService(t)
+	saveTestRoom(t, r, &dto.Room{
+		Id:      "room",
+		OwnerId: 1,
+		Status:  dto.RoomStatusActive,
+		Participants: []*dto.Participant{
+			{UId: 1, JoinTime: 1, State: dto.CallStateAccepted},
+			{UId: 2, JoinTime: 1, State: dto.CallStateAccepted},
+		},
+	})
+
+	if err := r.DestroyRoom("room", 1, dto.EndReasonHangup, baseDto.ThkClaims{}); err != nil {
+		t.Fatal(err)
+	}
+	room := findTestRoom(t, r, "room")
+	if room == nil || room.Status != dto.RoomStatusEnded || room.EndUId != 1 || room.EndReason != dto.EndReasonHangup {
+		t.Fatalf("unexpected room %+v", room)
+	}
+	if len(room.Participants) != 0 {
+		t.Fatalf("participants not cleaned %d", len(room.Participants))
+	}
+	ids, err := r.appCtx.RoomCache().SMembers(RoomsKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 0 {
+		t.Fatalf("room index not cleaned %v", ids)
+	}
+	// 重复销毁直接返回
+	if err = r.DestroyRoom("room", 2, dto.EndReasonHangup, baseDto.ThkClaims{}); err != nil {
+		t.Fatal(err)
+	}
+	if room = findTestRoom(t, r, "room"); room.EndUId != 1 {
+		t.Fatalf("end user changed %d", room.EndUId)
+	}
+}
+
+// 推流者停止推流后其他成员对该会话的拉流记录由服务端移除
+func TestStopPushClosesSubscriptionsLocalCache(t *testing.T) {
+	r := newTestService(t)
+	saveTestRoom(t, r, &dto.Room{
+		Id:      "room",
+		OwnerId: 1,
+		Status:  dto.RoomStatusActive,
+		Participants: []*dto.Participant{
+			{UId: 1, JoinTime: 1, StreamKey: "pub1", Tracks: []*dto.ParticipantTrack{{SessionId: "pub1", Mid: "0", Kind: dto.TrackKindAudio, TrackName: dto.TrackMic}}},
+			{UId: 2, JoinTime: 1, Subscriptions: []*dto.ParticipantTrack{
+				{SessionId: "sub2", RemoteSessionId: "pub1", Mid: "0", Kind: dto.TrackKindAudio, TrackName: dto.TrackMic},
+				{SessionId: "sub2", RemoteSessionId: "pub3", Mid: "1", Kind: dto.TrackKindAudio, TrackName: dto.TrackMic},
+			}},
+		},
+	})
+
+	err := r.OnUserStopPushEvent(&dto.RoomUserPushStreamEvent{RoomId: "room", UserId: 1, StreamKey: "pub1", Timestamp: 2}, baseDto.ThkClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := findTestParticipant(t, r, "room", 1); p.StreamKey != "" || len(p.Tracks) != 0 {
+		t.Fatalf("publisher not stopped %+v", p)
+	}
+	p := findTestParticipant(t, r, "room", 2)
+	if len(p.Subscriptions) != 1 || p.Subscriptions[0].RemoteSessionId != "pub3" {
+		t.Fatalf("unexpected subscriptions %+v", p.Subscriptions)
+	}
+}
+
+// 被踢出的成员解禁后不能直接回到通话, 需重新加入
+func TestKickMemberRequiresRejoinLocalCache(t *testing.T) {
+	r := newTestService(t)
+	saveTestRoom(t, r, &dto.Room{
+		Id:      "room",
+		Mode:    dto.ModeVoiceRoom,
+		OwnerId: 1,
+		Status:  dto.RoomStatusActive,
+		Participants: []*dto.Participant{
+			{UId: 1, JoinTime: 1, State: dto.CallStateAccepted, Role: dto.Broadcast},
+			{UId: 2, JoinTime: 1, State: dto.CallStateAccepted, Role: dto.Broadcast, RoomRole: dto.RoomRoleModerator},
+		},
+	})
+
+	// 解禁时间已过
+	kicked, err := r.KickMember("room", 2, time.Now().UnixMilli()-1, baseDto.ThkClaims{})
+	if err != nil || kicked == nil {
+		t.Fatal(kicked, err)
+	}
+	if banned, _ := r.IsMemberBanned("room", 2, baseDto.ThkClaims{}); banned {
+		t.Fatal("ban not expired")
+	}
+	room := findTestRoom(t, r, "room")
+	if room.HasCapability(2, dto.CapMuteOthers) {
+		t.Fatal("kicked moderator keeps capabilities")
+	}
+
+	err = r.OnUserJoinEvent(&dto.RoomUserJoinEvent{RoomId: "room", UserId: 2, Timestamp: time.Now().UnixMilli() + 1000}, baseDto.ThkClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := findTestParticipant(t, r, "room", 2); !p.IsKicked() || p.State != dto.CallStateKicked || p.LeaveTime == 0 {
+		t.Fatalf("kicked member joined without rejoin %+v", p)
+	}
+
+	if err = r.AddRoomMember("room", 2, 0, dto.Audience, baseDto.ThkClaims{}); err != nil {
+		t.Fatal(err)
+	}
+	p := findTestParticipant(t, r, "room", 2)
+	if p.IsKicked() || p.Role != dto.Audience || p.RoomRole != dto.RoomRoleMember {
+		t.Fatalf("unexpected member after rejoin %+v", p)
+	}
+	err = r.OnUserJoinEvent(&dto.RoomUserJoinEvent{RoomId: "room", UserId: 2, Timestamp: time.Now().UnixMilli() + 2000}, baseDto.ThkClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p = findTestParticipant(t, r, "room", 2); p.State != dto.CallStateAccepted || p.LeaveTime != 0 {
+		t.Fatalf("member not joined %+v", p)
+	}
+}
@@ -0,0 +1,109 @@
+package room
+
+import (
+	"fmt"
+	"time"
+
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	baseErr "github.com/thk-im/thk-im-base-server/errorx"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/errorx"
+)
+
+// endedRoomExpire 已结束的房间保留一段时间, 便于拒绝延迟到达的请求
+const endedRoomExpire = 5 * time.Minute
+
+func (r roomService) TransitRoomStatus(id string, status int, claims baseDto.ThkClaims) error {
+	lockerKey := fmt.Sprintf(RLockerKey, id)
+	locker := r.appCtx.NewRoomLocker(lockerKey, 3000, 3000)
+	success, errLock := locker.Lock()
+	if errLock != nil {
+		return errLock
+	}
+	if !success {
+		return baseErr.ErrInternalServerError
+	}
+	defer func() {
+		_, _ = locker.Release()
+	}()
+
+	room, errRoom := r.FindRoomById(id, claims)
+	if errRoom != nil {
+		return errRoom
+	}
+	if room == nil {
+		return errorx.ErrRoomNotExisted
+	}
+	return r.transitRoomStatus(room, status, claims)
+}
+
+// transitRoomStatus 校验并迁移房间状态, 成功后通知房间成员, 调用方需持有房间锁; 状态未变化时不做处理
+func (r roomService) transitRoomStatus(room *dto.Room, status int, claims baseDto.ThkClaims) error {
+	if room.Status == status {
+		return nil
+	}
+	if !room.CanTransitTo(status) {
+		r.appCtx.Logger().Errorf("transitRoomStatus %s invalid transition %d -> %d", room.Id, room.Status, status)
+		return errorx.ErrRoomStatusInvalid
+	}
+	oldStatus := room.Status
+	room.Status = status
+	room.StatusTime = time.Now().UnixMilli()
+	if err := r.saveRoom(room); err != nil {
+		return err
+	}
+
+	uIds := make([]int64, 0, len(room.Participants))
+	for _, p := range room.Participants {
+		uIds = append(uIds, p.UId)
+	}
+	if len(uIds) > 0 {
+		s := dto.MakeRoomStatusChangedSignal(room.Id, oldStatus, status, room.StatusTime)
+		if errPush := r.signalService.PushSignal(s, uIds, claims); errPush != nil {
+			r.appCtx.Logger().Error("transitRoomStatus PushSignal", room.Id, errPush)
+		}
+	}
+	return nil
+}
+
+// onMemberJoined 成员加入后更新房间的最大同时在线人数, 呼叫中的房间有主叫之外的成员接听后进入通话中,
+// 语音房/视频房有成员加入即进入通话中
+func (r roomService) onMemberJoined(id string, claims baseDto.ThkClaims) error {
+	lockerKey := fmt.Sprintf(RLockerKey, id)
+	locker := r.appCtx.NewRoomLocker(lockerKey, 3000, 3000)
+	success, errLock := locker.Lock()
+	if errLock != nil {
+		return errLock
+	}
+	if !success {
+		return baseErr.ErrInternalServerError
+	}
+	defer func() {
+		_, _ = locker.Release()
+	}()
+
+	room, errRoom := r.FindRoomById(id, claims)
+	if errRoom != nil {
+		return errRoom
+	}
+	if room == nil || room.IsEnded() {
+		return nil
+	}
+	joined := 0
+	for _, p := range room.Participants {
+		if p.JoinTime > 0 && p.LeaveTime == 0 {
+			joined++
+		}
+	}
+	peakChanged := joined > room.PeakCount
+	if peakChanged {
+		room.PeakCount = joined
+	}
+	if room.Status == dto.RoomStatusRinging && (joined > 1 || (joined == 1 && dto.IsLiveRoomMode(room.Mode))) {
+		return r.transitRoomStatus(room, dto.RoomStatusActive, claims)
+	}
+	if peakChanged {
+		return r.saveRoom(room)
+	}
+	return nil
+}
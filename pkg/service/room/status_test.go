@@ -0,0 +1,66 @@
+package room
+
+import (
+	"testing"
+
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/errorx"
+)
+
+// 房间状态迁移写入缓存, 不允许的迁移返回错误且状态不变
+func TestTransitRoomStatusLocalCache(t *testing.T) {
+	r := newTestService(t)
+	saveTestRoom(t, r, &dto.Room{
+		Id:           "room",
+		Mode:         dto.ModeAudio,
+		OwnerId:      1,
+		Status:       dto.RoomStatusRinging,
+		Participants: []*dto.Participant{{UId: 1}, {UId: 2}},
+	})
+	if room := findTestRoom(t, r, "room"); room.Status != dto.RoomStatusRinging {
+		t.Fatalf("ringing room loaded as %d", room.Status)
+	}
+
+	cases := []struct {
+		status  int
+		wantErr error
+		want    int
+	}{
+		{dto.RoomStatusEnded, errorx.ErrRoomStatusInvalid, dto.RoomStatusRinging},
+		{dto.RoomStatusActive, nil, dto.RoomStatusActive},
+		{dto.RoomStatusActive, nil, dto.RoomStatusActive},
+		{dto.RoomStatusRinging, errorx.ErrRoomStatusInvalid, dto.RoomStatusActive},
+		{dto.RoomStatusEnding, nil, dto.RoomStatusEnding},
+		{dto.RoomStatusEnded, nil, dto.RoomStatusEnded},
+		{dto.RoomStatusActive, errorx.ErrRoomStatusInvalid, dto.RoomStatusEnded},
+	}
+	for _, c := range cases {
+		if err := r.TransitRoomStatus("room", c.status, baseDto.ThkClaims{}); err != c.wantErr {
+			t.Fatalf("transit to %d err %v, want %v", c.status, err, c.wantErr)
+		}
+		if room := findTestRoom(t, r, "room"); room.Status != c.want {
+			t.Fatalf("transit to %d status %d, want %d", c.status, room.Status, c.want)
+		}
+	}
+	if err := r.TransitRoomStatus("missing", dto.RoomStatusActive, baseDto.ThkClaims{}); err != errorx.ErrRoomNotExisted {
+		t.Fatalf("unexpected err %v", err)
+	}
+}
+
+// 升级前写入缓存的房间没有状态字段, 按通话中处理
+func TestTransitLegacyRoomStatusLocalCache(t *testing.T) {
+	r := newTestService(t)
+	if err := r.appCtx.RoomCache().SetEx(r.getRoomCacheKey("room"), `{"id":"room","owner_id":1,"mode":1}`, 0); err != nil {
+		t.Fatal(err)
+	}
+	if room := findTestRoom(t, r, "room"); room == nil || room.Status != dto.RoomStatusActive {
+		t.Fatalf("unexpected legacy room %+v", room)
+	}
+	if err := r.TransitRoomStatus("room", dto.RoomStatusEnding, baseDto.ThkClaims{}); err != nil {
+		t.Fatal(err)
+	}
+	if room := findTestRoom(t, r, "room"); room.Status != dto.RoomStatusEnding {
+		t.Fatalf("status %d, want ending", room.Status)
+	}
+}
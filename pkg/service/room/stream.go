@@ -0,0 +1,194 @@
+package room
+
+import (
+	"math"
+	"time"
+
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+)
+
+const (
+	// StreamHeartbeatKey 推流心跳, member为roomId|uId|streamKey, score为最近一次心跳时间, 首次上报ing后开始检查
+	StreamHeartbeatKey = "live_server:stream:heartbeat"
+
+	defaultHeartbeatInterval = 10
+	defaultHeartbeatTimeout  = 30
+	streamHeartbeatBatch     = 100
+)
+
+func (r roomService) OnUserStopPushEvent(event *dto.RoomUserPushStreamEvent, claims baseDto.ThkClaims) error {
+	r.appCtx.Logger().Tracef("OnUserStopPushEvent %v", event)
+	_, _ = r.appCtx.RoomCache().ZRem(StreamHeartbeatKey, streamHeartbeatMember(event.RoomId, event.UserId, event.StreamKey))
+	room, errRoom := r.FindRoomById(event.RoomId, claims)
+	if errRoom != nil {
+		return errRoom
+	}
+	if room == nil {
+		return nil
+	}
+
+	var stopped *dto.Participant
+	_, err := r.updateParticipant(event.RoomId, event.UserId, func(participant *dto.Participant) (*dto.Participant, error) {
+		stopped = nil
+		if participant == nil || participant.StreamEventTime > event.Timestamp {
+			return nil, nil
+		}
+		if participant.StreamKey != event.StreamKey && !participant.OwnsSession(event.StreamKey) {
+			return nil, nil
+		}
+		copied := *participant
+		stopped = &copied
+		if participant.StreamKey == event.StreamKey {
+			participant.StreamKey = ""
+		}
+		tracks := make([]*dto.ParticipantTrack, 0, len(participant.Tracks))
+		for _, t := range participant.Tracks {
+			if t.SessionId != event.StreamKey {
+				tracks = append(tracks, t)
+			}
+		}
+		participant.Tracks = tracks
+		participant.StreamEventTime = event.Timestamp
+		return participant, nil
+	})
+	if err != nil {
+		return err
+	}
+	if stopped == nil {
+		return nil
+	}
+	uIds := make([]int64, 0)
+	for _, participant := range room.Participants {
+		if participant.UId != event.UserId {
+			uIds = append(uIds, participant.UId)
+		}
+	}
+
+	if closer, ok := r.engines[room.Engine].(TrackCloser); ok {
+		tracks := make([]*dto.ParticipantTrack, 0, len(stopped.Tracks))
+		for _, t := range stopped.Tracks {
+			if t.SessionId == event.StreamKey {
+				tracks = append(tracks, t)
+			}
+		}
+		if errClose := closer.CloseTracks(tracks); errClose != nil {
+			r.appCtx.Logger().Error("OnUserStopPushEvent CloseTracks", event, errClose)
+		}
+	}
+	r.closeSubscriptions(room, event.UserId, map[string]bool{event.StreamKey: true})
+	if len(uIds) > 0 {
+		pushSignal := dto.MakeParticipantLeaveSignal(event.RoomId, event.StreamKey, event.UserId, event.Timestamp)
+		if err := r.signalService.PushSignal(pushSignal, uIds, claims); err != nil {
+			r.appCtx.Logger().Error("OnUserStopPushEvent pushSignal", event, err, pushSignal)
+		}
+	}
+	return nil
+}
+
+// closeSubscriptions 推流会话结束后, 关闭其他成员拉流会话中拉取该会话的track并移除拉流记录
+func (r roomService) closeSubscriptions(room *dto.Room, publisherId int64, sessionIds map[string]bool) {
+	closer, _ := r.engines[room.Engine].(TrackCloser)
+	for _, p := range room.Participants {
+		if p.UId == publisherId {
+			continue
+		}
+		tracks := make([]*dto.ParticipantTrack, 0)
+		for _, t := range p.Subscriptions {
+			if sessionIds[t.RemoteSessionId] {
+				tracks = append(tracks, t)
+			}
+		}
+		if len(tracks) == 0 {
+			continue
+		}
+		if closer != nil {
+			if errClose := closer.CloseTracks(tracks); errClose != nil {
+				r.appCtx.Logger().Error("closeSubscriptions CloseTracks", room.Id, p.UId, errClose)
+			}
+		}
+		_, errUpdate := r.updateParticipant(room.Id, p.UId, func(participant *dto.Participant) (*dto.Participant, error) {
+			if participant == nil {
+				return nil, nil
+			}
+			subscriptions := make([]*dto.ParticipantTrack, 0, len(participant.Subscriptions))
+			for _, t := range participant.Subscriptions {
+				if !sessionIds[t.RemoteSessionId] {
+					subscriptions = append(subscriptions, t)
+				}
+			}
+			if len(subscriptions) == len(participant.Subscriptions) {
+				return nil, nil
+			}
+			participant.Subscriptions = subscriptions
+			return participant, nil
+		})
+		if errUpdate != nil {
+			r.appCtx.Logger().Error("closeSubscriptions updateParticipant", room.Id, p.UId, errUpdate)
+		}
+	}
+}
+
+func (r roomService) OnStreamHeartbeat(event *dto.RoomUserPushStreamEvent, claims baseDto.ThkClaims) error {
+	if event.StreamKey == "" {
+		return nil
+	}
+	member := streamHeartbeatMember(event.RoomId, event.UserId, event.StreamKey)
+	if _, err := r.appCtx.RoomCache().ZAdd(StreamHeartbeatKey, float64(event.Timestamp), member); err != nil {
+		return err
+	}
+	// 推流心跳同时作为成员心跳
+	return r.OnMemberHeartbeat(event.RoomId, event.UserId, claims)
+}
+
+func (r roomService) CheckStreamHeartbeat() error {
+	claims := newTaskClaims("CheckStreamHeartbeat")
+	now := time.Now().UnixMilli()
+	deadline := now - r.heartbeatTimeout()*1000
+	members, err := r.appCtx.RoomCache().ZRangeByScore(StreamHeartbeatKey, math.Inf(-1), float64(deadline), streamHeartbeatBatch)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		removed, errRem := r.appCtx.RoomCache().ZRemIfScoreBelow(StreamHeartbeatKey, member, float64(deadline))
+		if errRem != nil || !removed {
+			// 已被其他节点处理或重新上报了心跳
+			continue
+		}
+		roomId, uId, streamKey, ok := parseStreamHeartbeatMember(member)
+		if !ok {
+			continue
+		}
+		r.appCtx.Logger().Tracef("checkStreamHeartbeat stream timeout %s", member)
+		errStop := r.OnUserStopPushEvent(&dto.RoomUserPushStreamEvent{
+			RoomId:    roomId,
+			UserId:    uId,
+			StreamKey: streamKey,
+			Timestamp: now,
+		}, claims)
+		if errStop != nil {
+			r.appCtx.Logger().Errorf("checkStreamHeartbeat %s %v", member, errStop)
+		}
+	}
+	return nil
+}
+
+func (r roomService) heartbeatTimeout() int64 {
+	config := r.appCtx.LiveCallConfig().Heartbeat
+	if config == nil || config.Timeout <= 0 {
+		return defaultHeartbeatTimeout
+	}
+	return config.Timeout
+}
+
+func streamHeartbeatMember(roomId string, uId int64, streamKey string) string {
+	return encodeTaskMember(roomId, uId, streamKey)
+}
+
+func parseStreamHeartbeatMember(member string) (string, int64, string, bool) {
+	roomId, uId, fields, ok := decodeTaskMember(member, 1)
+	if !ok {
+		return "", 0, "", false
+	}
+	return roomId, uId, fields[0], true
+}
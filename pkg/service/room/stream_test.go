@@ -0,0 +1,61 @@
+package room
+
+import (
+	"testing"
+	"time"
+
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+)
+
+// 心跳超时的推流会话被停止, 重新上报了心跳的会话保留
+func TestCheckStreamHeartbeatLocalCache(t *testing.T) {
+	r := newTestService(t)
+	saveTestRoom(t, r, &dto.Room{
+		Id:      "room",
+		OwnerId: 1,
+		Status:  dto.RoomStatusActive,
+		Participants: []*dto.Participant{
+			{UId: 1, JoinTime: 1, StreamKey: "pub1", Tracks: []*dto.ParticipantTrack{
+				{SessionId: "pub1", Mid: "0", Kind: dto.TrackKindAudio, TrackName: dto.TrackMic},
+				{SessionId: "pub2", Mid: "0", Kind: dto.TrackKindVideo, TrackName: dto.TrackScreen},
+				{SessionId: "pub3", Mid: "0", Kind: dto.TrackKindVideo, TrackName: dto.TrackCamera},
+			}},
+			{UId: 2, JoinTime: 1},
+		},
+	})
+	now := time.Now().UnixMilli()
+	stale := now - (defaultHeartbeatTimeout+1)*1000
+	for _, streamKey := range []string{"pub1", "pub2", "pub3"} {
+		event := &dto.RoomUserPushStreamEvent{RoomId: "room", UserId: 1, StreamKey: streamKey, Timestamp: stale}
+		if err := r.OnStreamHeartbeat(event, baseDto.ThkClaims{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// pub2超时后又重新上报了心跳
+	if err := r.OnStreamHeartbeat(&dto.RoomUserPushStreamEvent{RoomId: "room", UserId: 1, StreamKey: "pub2", Timestamp: now}, baseDto.ThkClaims{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.CheckStreamHeartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	p := findTestParticipant(t, r, "room", 1)
+	if p.StreamKey != "" || len(p.Tracks) != 1 || p.Tracks[0].SessionId != "pub2" {
+		t.Fatalf("unexpected publisher %+v", p)
+	}
+	cases := []struct {
+		streamKey string
+		existed   bool
+	}{
+		{"pub1", false},
+		{"pub2", true},
+		{"pub3", false},
+	}
+	for _, c := range cases {
+		_, existed, err := r.appCtx.RoomCache().ZScore(StreamHeartbeatKey, streamHeartbeatMember("room", 1, c.streamKey))
+		if err != nil || existed != c.existed {
+			t.Errorf("stream %s heartbeat existed %v, want %v, err %v", c.streamKey, existed, c.existed, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package room
+
+import (
+	"fmt"
+	"time"
+
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+)
+
+// UserRoomKey 用户当前所在的房间, 加入时写入, 离开/被踢出/房间销毁时删除
+const UserRoomKey = "live_server:user:%d:room"
+
+func (r roomService) FindUserRoom(uId int64, claims baseDto.ThkClaims) (string, error) {
+	key := r.getUserRoomCacheKey(uId)
+	roomId, err := r.getString(key)
+	if err != nil || roomId == "" {
+		return "", err
+	}
+	// 房间过期或成员已离开时索引可能残留
+	participant, errFind := r.findParticipant(roomId, uId)
+	if errFind != nil {
+		return "", errFind
+	}
+	if participant == nil || participant.JoinTime == 0 || participant.LeaveTime > 0 {
+		r.clearUserRoom(roomId, uId)
+		return "", nil
+	}
+	return roomId, nil
+}
+
+// setUserRoom 索引与房间存活时间一致, 心跳时续期
+func (r roomService) setUserRoom(id string, uId int64) {
+	if err := r.appCtx.RoomCache().SetEx(r.getUserRoomCacheKey(uId), id, time.Hour); err != nil {
+		r.appCtx.Logger().Error("setUserRoom", id, uId, err)
+	}
+}
+
+// clearUserRoom 只删除指向该房间的索引, 用户可能已加入其他房间
+func (r roomService) clearUserRoom(id string, uId int64) {
+	key := r.getUserRoomCacheKey(uId)
+	roomId, err := r.getString(key)
+	if err != nil || roomId != id {
+		return
+	}
+	if errDel := r.appCtx.RoomCache().Del(key); errDel != nil {
+		r.appCtx.Logger().Error("clearUserRoom", id, uId, errDel)
+	}
+}
+
+func (r roomService) getUserRoomCacheKey(uId int64) string {
+	return fmt.Sprintf(UserRoomKey, uId)
+}
@@ -0,0 +1,99 @@
+package room
+
+import (
+	"fmt"
+	"time"
+
+	baseDto "github.com/thk-im/thk-im-base-server/dto"
+	baseErr "github.com/thk-im/thk-im-base-server/errorx"
+	"github.com/thk-im/thk-im-livecall-server/pkg/app"
+	"github.com/thk-im/thk-im-livecall-server/pkg/dto"
+	rtcDto "github.com/thk-im/thk-im-rtc-server/pkg/dto"
+)
+
+// webRTCEngine thk-im-rtc-server引擎, 参与人加入/离开由rtc_event回调维护
+type webRTCEngine struct {
+	appCtx  *app.Context
+	service Service
+}
+
+func newWebRTCEngine(appCtx *app.Context, service Service) Engine {
+	return &webRTCEngine{appCtx: appCtx, service: service}
+}
+
+func (e webRTCEngine) Name() string {
+	return dto.EngineWebRTC
+}
+
+func (e webRTCEngine) IsParticipantAlive(participant *dto.Participant) (bool, error) {
+	return participant.JoinTime > 0 && participant.LeaveTime == 0, nil
+}
+
+func (e webRTCEngine) PublishStream(room *dto.Room, req *dto.PublishStreamReq, claims baseDto.ThkClaims) (*dto.PublishStreamResp, error) {
+	audioEnable, videoEnable := room.MediaParams.AudioEnable(), room.MediaParams.VideoEnable()
+	pubReq := &rtcDto.PublishReq{
+		ChannelId:   room.Id,
+		UId:         fmt.Sprintf("%d", req.Uid),
+		OfferSdp:    req.Sdp,
+		AudioEnable: audioEnable,
+		VideoEnable: videoEnable,
+	}
+	pubResp, errPub := e.appCtx.WebRTCApi().Publish(pubReq, claims)
+
+	if errPub != nil {
+		e.appCtx.Logger().Error("PublishStream err, ", errPub)
+		return nil, baseErr.ErrInternalServerError
+	}
+
+	if pubResp == nil {
+		e.appCtx.Logger().Error("PublishStream resp err", pubResp)
+		return nil, baseErr.ErrInternalServerError
+	}
+
+	_ = e.service.OnUserJoinEvent(&dto.RoomUserJoinEvent{
+		RoomId:    room.Id,
+		UserId:    req.Uid,
+		Timestamp: time.Now().UnixMilli(),
+	}, claims)
+
+	tracks := make([]*dto.ParticipantTrack, 0, 2)
+	if audioEnable {
+		tracks = append(tracks, &dto.ParticipantTrack{SessionId: pubResp.SessionId, Kind: dto.TrackKindAudio, TrackName: dto.TrackMic})
+	}
+	if videoEnable {
+		tracks = append(tracks, &dto.ParticipantTrack{SessionId: pubResp.SessionId, Kind: dto.TrackKindVideo, TrackName: dto.TrackCamera})
+	}
+	if errSave := e.service.UpdateMemberTracks(room.Id, req.Uid, tracks, claims); errSave != nil {
+		e.appCtx.Logger().Error("PublishStream UpdateMemberTracks err, ", errSave)
+	}
+
+	// rtc-server只接收音视频开关, 码率/分辨率/帧率上限写入answer, 由客户端按answer限制发送
+	answer := dto.LimitSdpMedia(pubResp.AnswerSdp, room.MediaParams)
+	return &dto.PublishStreamResp{SessionId: pubResp.SessionId, Sdp: answer, Type: "answer"}, nil
+}
+
+func (e webRTCEngine) SubscribeStream(room *dto.Room, req *dto.SubscribeStreamReq, claims baseDto.ThkClaims) (*dto.SubscribeStreamResp, error) {
+	playReq := &rtcDto.PlayReq{
+		ChannelId: room.Id,
+		UId:       fmt.Sprintf("%d", req.Uid),
+		OfferSdp:  req.Sdp,
+		SessionId: req.SessionId,
+	}
+	playResp, errPlay := e.appCtx.WebRTCApi().Play(playReq, claims)
+	if errPlay != nil {
+		e.appCtx.Logger().Error("SubscribeStream err, ", errPlay)
+		return nil, baseErr.ErrInternalServerError
+	}
+
+	if playResp == nil {
+		e.appCtx.Logger().Error("SubscribeStream resp err", playResp)
+		return nil, baseErr.ErrInternalServerError
+	}
+
+	res := &dto.SubscribeStreamResp{
+		Renegotiation: false,
+		Sdp:           playResp.AnswerSdp,
+		Type:          "answer",
+	}
+	return res, nil
+}
@@ -14,7 +14,8 @@ type Service struct {
 	appCtx *app.Context
 }
 
-const CallMsgType = 14
+// DefaultCallMsgType 未配置CallMsg时通话结束消息的类型
+const DefaultCallMsgType = 14
 
 func NewSignalService(appCtx *app.Context) Service {
 	return Service{appCtx: appCtx}
@@ -34,6 +35,9 @@ func (s Service) PushSignal(signal *dto.LiveCallSignal, toUIds []int64, claims b
 	return errPush
 }
 
+// SendLiveCallMsgByEnded 通话结束后发送会话消息, 默认向整个会话发送一条通话小结;
+// 开启CallMsg.PerMember时按成员发送: 参与过通话的成员收到自己的通话小结, 超时未接听或呼叫被取消的被邀请人收到未接来电,
+// 其他房间成员(拒绝接听、未加入的主叫等)收到整体的通话小结, 未进入房间的会话成员不会收到消息
 func (s Service) SendLiveCallMsgByEnded(room *dto.Room, claims baseDto.ThkClaims) error {
 	if s.appCtx.MsgApi() == nil {
 		return nil
@@ -48,7 +52,38 @@ func (s Service) SendLiveCallMsgByEnded(room *dto.Room, claims baseDto.ThkClaims
 	}
 	s.appCtx.Logger().Trace("SendLiveCallMsgByEnded", roomJson)
 
+	summaryType, missedType := s.callMsgTypes()
 	callMsg := dto.BuildCallMsg(room)
+	if config := s.appCtx.LiveCallConfig().CallMsg; config == nil || !config.PerMember {
+		// 不指定接收人, 会话内所有成员都收到通话小结
+		return s.sendCallMsg(room, summaryType, room.OwnerId, callMsg, nil, claims)
+	}
+	others := make([]int64, 0)
+	var err error
+	for _, p := range room.Participants {
+		var errSend error
+		if p.AnswerTime > 0 {
+			errSend = s.sendCallMsg(room, summaryType, room.OwnerId, callMsg.AttendeeView(p), []int64{p.UId}, claims)
+		} else if p.InviterId > 0 && (p.State == dto.CallStateTimeout || p.State == dto.CallStateCanceled) {
+			errSend = s.sendCallMsg(room, missedType, p.InviterId, callMsg.MissedView(), []int64{p.UId}, claims)
+		} else {
+			others = append(others, p.UId)
+		}
+		if errSend != nil {
+			s.appCtx.Logger().Error("SendLiveCallMsgByEnded", room.Id, p.UId, errSend)
+			err = errSend
+		}
+	}
+	if len(others) > 0 {
+		if errSend := s.sendCallMsg(room, summaryType, room.OwnerId, callMsg, others, claims); errSend != nil {
+			s.appCtx.Logger().Error("SendLiveCallMsgByEnded", room.Id, others, errSend)
+			err = errSend
+		}
+	}
+	return err
+}
+
+func (s Service) sendCallMsg(room *dto.Room, msgType int, fUId int64, callMsg dto.CallMsg, receivers []int64, claims baseDto.ThkClaims) error {
 	msgBody, errBody := json.Marshal(callMsg)
 	if errBody != nil {
 		return errBody
@@ -56,15 +91,31 @@ func (s Service) SendLiveCallMsgByEnded(room *dto.Room, claims baseDto.ThkClaims
 	req := &msgDto.SendMessageReq{
 		CId:       s.appCtx.SnowflakeNode().Generate().Int64(),
 		SId:       *room.SessionId,
-		Type:      CallMsgType,
+		Type:      msgType,
 		CTime:     time.Now().UnixMilli(),
 		Body:      string(msgBody),
-		FUid:      room.OwnerId,
+		FUid:      fUId,
 		RMsgId:    nil,
 		AtUsers:   nil,
-		Receivers: nil,
+		Receivers: receivers,
 		ExtData:   nil,
 	}
 	_, errSend := s.appCtx.MsgApi().SendSessionMessage(req, claims)
 	return errSend
 }
+
+// callMsgTypes 通话小结和未接来电的消息类型, 未配置时使用DefaultCallMsgType
+func (s Service) callMsgTypes() (int, int) {
+	summaryType, missedType := DefaultCallMsgType, DefaultCallMsgType
+	config := s.appCtx.LiveCallConfig().CallMsg
+	if config == nil {
+		return summaryType, missedType
+	}
+	if config.SummaryType > 0 {
+		summaryType = config.SummaryType
+	}
+	if config.MissedType > 0 {
+		missedType = config.MissedType
+	}
+	return summaryType, missedType
+}
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS `live_call_leg_%s`
+(
+    `room_id`     VARCHAR(64) NOT NULL COMMENT '房间id',
+    `u_id`        BIGINT      NOT NULL COMMENT '用户id',
+    `session_id`  BIGINT      NOT NULL DEFAULT 0 COMMENT '会话id',
+    `owner_id`    BIGINT      NOT NULL COMMENT '房间创建者id',
+    `mode`        TINYINT     NOT NULL COMMENT '1普通聊天 2语音电话 3视频电话 4语音房 5视频房',
+    `engine`      VARCHAR(32) NOT NULL DEFAULT '' COMMENT 'RTC引擎',
+    `role`        TINYINT     NOT NULL DEFAULT 0 COMMENT '1观众 2推流',
+    `join_time`   BIGINT      NOT NULL DEFAULT 0 COMMENT '加入时间',
+    `leave_time`  BIGINT      NOT NULL DEFAULT 0 COMMENT '离开时间',
+    `refuse_time` BIGINT      NOT NULL DEFAULT 0 COMMENT '拒绝时间',
+    `kick_time`   BIGINT      NOT NULL DEFAULT 0 COMMENT '被踢出时间',
+    `duration`    BIGINT      NOT NULL DEFAULT 0 COMMENT '通话时长 单位ms',
+    `create_time` BIGINT      NOT NULL COMMENT '房间创建时间',
+    `end_time`    BIGINT      NOT NULL COMMENT '房间结束时间',
+    PRIMARY KEY (`u_id`, `room_id`),
+    INDEX `CALL_LEG_USER_IDX` (`u_id`, `create_time`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS `live_call_record_%s`
+(
+    `room_id`      VARCHAR(64) NOT NULL COMMENT '房间id',
+    `session_id`   BIGINT      NOT NULL DEFAULT 0 COMMENT '会话id',
+    `owner_id`     BIGINT      NOT NULL COMMENT '房间创建者id',
+    `mode`         TINYINT     NOT NULL COMMENT '1普通聊天 2语音电话 3视频电话 4语音房 5视频房',
+    `engine`       VARCHAR(32) NOT NULL DEFAULT '' COMMENT 'RTC引擎',
+    `accepted`     TINYINT     NOT NULL DEFAULT 0 COMMENT '0未接听 1被挂断 2已接通 3通话中被挂断',
+    `accept_time`  BIGINT      NOT NULL DEFAULT 0 COMMENT '接通时间',
+    `duration`     BIGINT      NOT NULL DEFAULT 0 COMMENT '通话时长 单位ms',
+    `member_count` INT         NOT NULL DEFAULT 0 COMMENT '参与人数',
+    `create_time`  BIGINT      NOT NULL COMMENT '房间创建时间',
+    `end_time`     BIGINT      NOT NULL COMMENT '房间结束时间',
+    PRIMARY KEY (`room_id`),
+    INDEX `CALL_RECORD_SESSION_IDX` (`session_id`, `create_time`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;